@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"tianji-reporter/utils"
+	"time"
+)
+
+/**
+ * Config holds every reporter option. The value of an option is decided in
+ * this order, the first one wins:
+ *   1. command line flag, e.g. -interval 10
+ *   2. environment variable, e.g. TIANJI_INTERVAL=10
+ *   3. config file passed by -config or TIANJI_CONFIG
+ *   4. default value
+ */
+type Config struct {
+	URL        string                 `json:"url"`
+	Workspace  string                 `json:"workspace"`
+	Name       string                 `json:"name"`
+	Interval   int                    `json:"interval"`
+	Mode       string                 `json:"mode"`
+	Vnstat     bool                   `json:"vnstat"`
+	Verbose    bool                   `json:"verbose"`
+	Silent     bool                   `json:"silent"`
+	Spool      SpoolConfig            `json:"spool"`
+	Retry      RetryConfig            `json:"retry"`
+	Collectors utils.CollectorsConfig `json:"collectors"`
+}
+
+type SpoolConfig struct {
+	Dir     string         `json:"dir"`
+	MaxSize int            `json:"max_size"` // MB
+	MaxAge  utils.Duration `json:"max_age"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int            `json:"max_attempts"`
+	AttemptTimeout utils.Duration `json:"attempt_timeout"`
+	InitialBackoff utils.Duration `json:"initial_backoff"`
+	MaxBackoff     utils.Duration `json:"max_backoff"`
+	Multiplier     float64        `json:"multiplier"`
+	Jitter         float64        `json:"jitter"`
+}
+
+func (c RetryConfig) Policy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    c.MaxAttempts,
+		AttemptTimeout: time.Duration(c.AttemptTimeout),
+		InitialBackoff: time.Duration(c.InitialBackoff),
+		MaxBackoff:     time.Duration(c.MaxBackoff),
+		Multiplier:     c.Multiplier,
+		Jitter:         c.Jitter,
+	}
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval: 5,
+		Mode:     "http",
+		Spool: SpoolConfig{
+			Dir:     defaultSpoolDir(),
+			MaxSize: 50,
+			MaxAge:  utils.Duration(24 * time.Hour),
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    DefaultRetryPolicy.MaxAttempts,
+			AttemptTimeout: utils.Duration(DefaultRetryPolicy.AttemptTimeout),
+			InitialBackoff: utils.Duration(DefaultRetryPolicy.InitialBackoff),
+			MaxBackoff:     utils.Duration(DefaultRetryPolicy.MaxBackoff),
+			Multiplier:     DefaultRetryPolicy.Multiplier,
+			Jitter:         DefaultRetryPolicy.Jitter,
+		},
+		Collectors: utils.DefaultCollectorsConfig(),
+	}
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Mode, "mode", cfg.Mode, "The send mode of report data, you can select: 'http' or 'udp', default is 'http'")
+	fs.StringVar(&cfg.URL, "url", cfg.URL, "The http url of tianji, for example: https://tianji.dev")
+	fs.StringVar(&cfg.Workspace, "workspace", cfg.Workspace, "The workspace id for tianji, this should be a uuid")
+	fs.StringVar(&cfg.Name, "name", cfg.Name, "The identification name for this machine")
+	fs.IntVar(&cfg.Interval, "interval", cfg.Interval, "Input the INTERVAL, seconed")
+	fs.BoolVar(&cfg.Vnstat, "vnstat", cfg.Vnstat, "Use vnstat for traffic statistics, linux only")
+	fs.BoolVar(&cfg.Verbose, "verbose", cfg.Verbose, "Enable verbose logging to show full payload content")
+	fs.BoolVar(&cfg.Silent, "silent", cfg.Silent, "Enable silent mode to suppress success logs")
+	fs.StringVar(&cfg.Spool.Dir, "spool-dir", cfg.Spool.Dir, "The directory to keep reports which failed to send, set empty to disable")
+	fs.IntVar(&cfg.Spool.MaxSize, "spool-max-size", cfg.Spool.MaxSize, "The max size of spooled reports, MB")
+	fs.DurationVar((*time.Duration)(&cfg.Spool.MaxAge), "spool-max-age", time.Duration(cfg.Spool.MaxAge), "The max age of spooled reports, older reports will be dropped")
+	fs.IntVar(&cfg.Retry.MaxAttempts, "retry-max", cfg.Retry.MaxAttempts, "The max attempts to send a report over http")
+	fs.DurationVar((*time.Duration)(&cfg.Retry.AttemptTimeout), "retry-attempt-timeout", time.Duration(cfg.Retry.AttemptTimeout), "The max time to wait for the server to answer one attempt")
+	fs.DurationVar((*time.Duration)(&cfg.Retry.InitialBackoff), "retry-backoff", time.Duration(cfg.Retry.InitialBackoff), "The wait before the first retry, it doubles on every retry")
+	fs.DurationVar((*time.Duration)(&cfg.Retry.MaxBackoff), "retry-max-backoff", time.Duration(cfg.Retry.MaxBackoff), "The max wait between two retries")
+}
+
+/**
+ * Parse the command line, config file and environment variables into a config
+ */
+func parseConfig(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := DefaultConfig()
+
+	var configPath string
+	fs.StringVar(&configPath, "config", "", "The path of config file, support .yaml, .yml, .toml and .json")
+	bindFlags(fs, &cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// Remember flags from command line, they are applied again at last
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if configPath == "" {
+		configPath, _ = lookupEnv(envName("config"))
+	}
+	if configPath != "" {
+		if err := loadConfigFile(configPath, &cfg); err != nil {
+			return cfg, fmt.Errorf("load config file %s: %w", configPath, err)
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		if value, ok := lookupEnv(envName(f.Name)); ok {
+			if err := f.Value.Set(value); err != nil && envErr == nil {
+				envErr = fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), err)
+			}
+		}
+	})
+	if envErr != nil {
+		return cfg, envErr
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// envName returns the environment variable of a flag, e.g. spool-dir -> TIANJI_SPOOL_DIR
+func envName(flagName string) string {
+	return "TIANJI_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+/**
+ * Load config file into cfg, keys missing in the file keep their value.
+ * YAML and TOML are converted to JSON first, so the struct only needs json tags.
+ */
+func loadConfigFile(path string, cfg *Config) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buf, &raw)
+	case ".toml":
+		err = toml.Unmarshal(buf, &raw)
+	case ".json":
+		err = jsoniter.Unmarshal(buf, &raw)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := jsoniter.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	decoder := jsoniter.Config{DisallowUnknownFields: true}.Froze()
+	return decoder.Unmarshal(jsonData, cfg)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"tianji-reporter/utils"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func parseTestConfig(args []string, env map[string]string) (Config, error) {
+	fs := flag.NewFlagSet("tianji-reporter", flag.ContinueOnError)
+	return parseConfig(fs, args, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+url: https://tianji.example.com
+workspace: yaml-workspace
+interval: 10
+spool:
+  max_age: 1h
+retry:
+  max_attempts: 5
+  initial_backoff: 2
+`,
+		"config.toml": `
+url = "https://tianji.example.com"
+workspace = "toml-workspace"
+interval = 10
+
+[spool]
+max_age = "1h"
+
+[retry]
+max_attempts = 5
+initial_backoff = "2s"
+`,
+		"config.json": `{
+  "url": "https://tianji.example.com",
+  "workspace": "json-workspace",
+  "interval": 10,
+  "spool": { "max_age": "1h" },
+  "retry": { "max_attempts": 5, "initial_backoff": "2s" }
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := parseTestConfig([]string{"-config", writeConfigFile(t, name, content)}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "https://tianji.example.com", cfg.URL)
+			assert.Contains(t, cfg.Workspace, "-workspace")
+			assert.Equal(t, 10, cfg.Interval)
+			assert.Equal(t, utils.Duration(time.Hour), cfg.Spool.MaxAge)
+			assert.Equal(t, 5, cfg.Retry.MaxAttempts)
+			assert.Equal(t, utils.Duration(2*time.Second), cfg.Retry.InitialBackoff)
+
+			// Values missing in the file keep their default
+			assert.Equal(t, "http", cfg.Mode)
+			assert.Equal(t, 50, cfg.Spool.MaxSize)
+			assert.Equal(t, DefaultRetryPolicy.MaxBackoff, cfg.Retry.Policy().MaxBackoff)
+			assert.Equal(t, DefaultRetryPolicy.AttemptTimeout, cfg.Retry.Policy().AttemptTimeout)
+		})
+	}
+}
+
+func TestLoadConfigFileUnknownKey(t *testing.T) {
+	_, err := parseTestConfig([]string{"-config", writeConfigFile(t, "config.yaml", "intervall: 10\n")}, nil)
+	assert.Error(t, err)
+
+	_, err = parseTestConfig([]string{"-config", writeConfigFile(t, "config.ini", "interval=10\n")}, nil)
+	assert.Error(t, err)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+url: https://file.example.com
+workspace: file-workspace
+name: file-name
+interval: 10
+silent: true
+`)
+
+	cfg, err := parseTestConfig([]string{"-interval", "30"}, map[string]string{
+		"TIANJI_CONFIG":    path,
+		"TIANJI_WORKSPACE": "env-workspace",
+		"TIANJI_INTERVAL":  "20",
+		"TIANJI_SILENT":    "false",
+		"TIANJI_RETRY_MAX": "1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://file.example.com", cfg.URL)
+	assert.Equal(t, "env-workspace", cfg.Workspace)
+	assert.Equal(t, "file-name", cfg.Name)
+	assert.Equal(t, 30, cfg.Interval)
+	assert.False(t, cfg.Silent)
+	assert.Equal(t, 1, cfg.Retry.MaxAttempts)
+
+	_, err = parseTestConfig(nil, map[string]string{"TIANJI_INTERVAL": "soon"})
+	assert.Error(t, err)
+}
+
+func TestLoadConfigFileCollectors(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+collectors:
+  docker:
+    enabled: false
+  traffic:
+    timeout: 3s
+    vnstat: true
+  connectivity:
+    ipv4: ["10.0.0.1:443"]
+`)
+
+	cfg, err := parseTestConfig([]string{"-config", path}, nil)
+	assert.NoError(t, err)
+	assert.False(t, cfg.Collectors.Docker.Enabled)
+	assert.True(t, cfg.Collectors.Traffic.Enabled)
+	assert.True(t, cfg.Collectors.Traffic.Vnstat)
+	assert.Equal(t, utils.Duration(3*time.Second), cfg.Collectors.Traffic.Timeout)
+	assert.True(t, cfg.Collectors.CPU.Enabled)
+
+	// Connectivity only dials the configured targets
+	assert.Equal(t, []string{"10.0.0.1:443"}, cfg.Collectors.Connectivity.IPv4)
+	assert.Empty(t, cfg.Collectors.Connectivity.IPv6)
+}
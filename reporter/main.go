@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
+	"fmt"
 	jsoniter "github.com/json-iterator/go"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"tianji-reporter/utils"
 	"time"
 )
@@ -17,27 +22,30 @@ type ReportData struct {
 	WorkspaceId string                  `json:"workspaceId"`
 	Name        string                  `json:"name"`
 	Hostname    string                  `json:"hostname"`
-	Timeout     int                     `json:"timeout"` // if service receive after timeout second, its means client are offline
+	Timeout     int                     `json:"timeout"`          // if service receive after timeout second, its means client are offline
+	Timestamp   int64                   `json:"timestamp"`        // unix milliseconds when the payload was collected
+	Replay      bool                    `json:"replay,omitempty"` // sent from the spool, the server does not take it as the current status
 	Payload     utils.ReportDataPayload `json:"payload"`
 }
 
-var (
-	Mode        = flag.String("mode", "http", "The send mode of report data, you can select: 'http' or 'udp', default is 'http'")
-	Url         = flag.String("url", "", "The http url of tianji, for example: https://tianji.dev")
-	WorkspaceId = flag.String("workspace", "", "The workspace id for tianji, this should be a uuid")
-	Name        = flag.String("name", "", "The identification name for this machine")
-	Interval    = flag.Int("interval", 5.0, "Input the INTERVAL, seconed")
-	IsVnstat    = flag.Bool("vnstat", false, "Use vnstat for traffic statistics, linux only")
-	Verbose     = flag.Bool("verbose", false, "Enable verbose logging to show full payload content")
-	Silent      = flag.Bool("silent", false, "Enable silent mode to suppress success logs")
-)
+// The reporter config, it is only written once on startup
+var config = DefaultConfig()
+
+// The server keeps the last 20 reports of every machine as its history, only
+// the newest spooled reports which still fit in next to the live one are
+// replayed, older ones would be thrown away by the server anyway
+const spoolReplayWindow = 19
 
 var version = "1.0.0"
 
 func main() {
-	flag.Parse()
+	var err error
+	config, err = parseConfig(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatal("Invalid config: ", err)
+	}
 
-	parsedURL, err := url.Parse(*Url)
+	parsedURL, err := url.Parse(config.URL)
 
 	if err != nil {
 		log.Fatal("Invalid URL:", err)
@@ -47,67 +55,182 @@ func main() {
 		log.Fatal("Invalid URL: Missing scheme")
 	}
 
-	if *WorkspaceId == "" {
+	if config.Workspace == "" {
 		log.Fatal("WORKSPACE_ID must not be blank!")
 	}
 
+	if config.Interval <= 0 {
+		log.Fatal("INTERVAL must be greater than 0!")
+	}
+
 	hostname, _ := os.Hostname()
 	var name string
-	if *Name != "" {
-		name = *Name
+	if config.Name != "" {
+		name = config.Name
 	} else {
 		name = hostname
 	}
 
-	interval := *Interval
+	interval := config.Interval
 
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
 	httpClient := &http.Client{}
 
+	var spool *Spool
+	if config.Spool.Dir != "" {
+		spool, err = NewSpool(config.Spool.Dir, int64(config.Spool.MaxSize)*1024*1024, time.Duration(config.Spool.MaxAge))
+		if err != nil {
+			log.Println("Create spool error, failed reports will be dropped:", err)
+		}
+	}
+
+	retryPolicy := config.Retry.Policy()
+
+	// Nothing is sent before the time the server asked for with Retry-After
+	var retryNotBefore time.Time
+	send := func(ctx context.Context, data ReportData) error {
+		if config.Mode == "udp" {
+			return sendUDPPack(*parsedURL, data)
+		}
+		if time.Now().Before(retryNotBefore) {
+			return &ReportError{
+				Retryable: true,
+				Err:       fmt.Errorf("server asked to retry after %s", retryNotBefore.Format(time.RFC3339)),
+			}
+		}
+		err := sendHTTPRequest(ctx, *parsedURL, data, httpClient, retryPolicy)
+		if retryAfter := retryAfter(err); retryAfter > 0 {
+			retryNotBefore = time.Now().Add(retryAfter)
+		}
+		return err
+	}
+
+	if config.Vnstat {
+		config.Collectors.Traffic.Vnstat = true
+	}
+	registry := utils.NewDefaultRegistry(config.Collectors)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	registry.Start(ctx)
+
 	log.Println("Start reporting...")
-	log.Println("Mode:", *Mode)
+	log.Println("Mode:", config.Mode)
 	log.Println("Version:", version)
+	log.Println("Collectors:", strings.Join(registry.Names(), ", "))
+	if spool != nil {
+		log.Println("Spool:", config.Spool.Dir)
+	}
 
-	for {
-		if !*Silent {
+	var lastPayload utils.ReportDataPayload
+	for ctx.Err() == nil {
+		if !config.Silent {
 			log.Println("Sending report data to:", parsedURL.String())
 		}
 		payload := ReportData{
-			WorkspaceId: *WorkspaceId,
+			WorkspaceId: config.Workspace,
 			Name:        name,
 			Hostname:    hostname,
 			Timeout:     interval * 10,
-			Payload:     utils.GetReportDataPaylod(interval, *IsVnstat),
+			Timestamp:   time.Now().UnixMilli(),
+			Payload:     registry.Collect(ctx),
 		}
 
-		if *Mode == "udp" {
-			sendUDPPack(*parsedURL, payload)
-		} else {
-			sendHTTPRequest(*parsedURL, payload, httpClient)
+		// The collection is incomplete if it was interrupted
+		if ctx.Err() != nil {
+			break
 		}
+		lastPayload = payload.Payload
+
+		deliver(func(data ReportData) error {
+			return send(ctx, data)
+		}, spool, payload)
 
-		<-ticker.C
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	stop()
+	log.Println("Shutting down, sending offline report...")
+
+	// Timeout 0 tells the server that this machine is offline now
+	offlineCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	offlinePolicy := retryPolicy
+	offlinePolicy.MaxAttempts = 1
+	offline := ReportData{
+		WorkspaceId: config.Workspace,
+		Name:        name,
+		Hostname:    hostname,
+		Timeout:     0,
+		Timestamp:   time.Now().UnixMilli(),
+		Payload:     lastPayload,
+	}
+	if config.Mode == "udp" {
+		sendUDPPack(*parsedURL, offline)
+	} else {
+		sendHTTPRequest(offlineCtx, *parsedURL, offline, httpClient, offlinePolicy)
+	}
+
+	log.Println("Bye")
+}
+
+/**
+ * Send the report, a report which failed to send is queued in the spool.
+ * Queued reports are only replayed after the live report went through, so
+ * a broken backlog can never hold up the current status
+ */
+func deliver(send func(ReportData) error, spool *Spool, payload ReportData) {
+	err := send(payload)
+	if spool == nil {
+		return
+	}
+	if err != nil {
+		if isRetryable(err) {
+			pushSpool(spool, payload)
+		}
+		return
+	}
+
+	if spool.Len() > 0 {
+		sent, _ := spool.Replay(send, spoolReplayWindow)
+		if sent > 0 {
+			log.Printf("Replayed %d spooled reports, %d left\n", sent, spool.Len())
+		}
+	}
+}
+
+func pushSpool(spool *Spool, payload ReportData) {
+	if err := spool.Push(payload); err != nil {
+		log.Println("Spool report error:", err)
+		return
+	}
+
+	if !config.Silent {
+		log.Println("Report saved to spool, it will be sent later")
 	}
 }
 
 /**
  * Send UDP Pack to report server data
  */
-func sendUDPPack(url url.URL, payload ReportData) {
+func sendUDPPack(url url.URL, payload ReportData) error {
 	// parse target url
 	addr, err := net.ResolveUDPAddr("udp", url.Hostname()+":"+url.Port())
 	if err != nil {
 		log.Println("Error resolving address:", err)
-		return
+		return err
 	}
 
 	// create UDP connection
 	conn, err := net.DialUDP("udp", nil, addr)
 	if err != nil {
 		log.Println("Error creating connection:", err)
-		return
+		return err
 	}
 	defer conn.Close()
 
@@ -115,11 +238,11 @@ func sendUDPPack(url url.URL, payload ReportData) {
 	jsonData, err := jsoniter.Marshal(payload)
 	if err != nil {
 		log.Println("Error encoding JSON:", err)
-		return
+		return err
 	}
 
-	if !*Silent {
-		if *Verbose {
+	if !config.Silent {
+		if config.Verbose {
 			log.Printf("[Report] %s\n", jsonData)
 		} else {
 			log.Printf("[Report] Payload length: %d bytes\n", len(jsonData))
@@ -130,26 +253,28 @@ func sendUDPPack(url url.URL, payload ReportData) {
 	_, err = conn.Write(jsonData)
 	if err != nil {
 		log.Println("Error sending message:", err)
-		return
+		return err
 	}
 
-	if !*Silent {
+	if !config.Silent {
 		log.Println("Message sent successfully!")
 	}
+
+	return nil
 }
 
 /**
  * Send HTTP Request to report server data
  */
-func sendHTTPRequest(_url url.URL, payload ReportData, client *http.Client) {
+func sendHTTPRequest(ctx context.Context, _url url.URL, payload ReportData, client *http.Client, policy RetryPolicy) error {
 	jsonData, err := jsoniter.Marshal(payload)
 	if err != nil {
 		log.Println("Error encoding JSON:", err)
-		return
+		return &ReportError{Err: err}
 	}
 
-	if !*Silent {
-		if *Verbose {
+	if !config.Silent {
+		if config.Verbose {
 			log.Printf("[Report] %s\n", jsonData)
 		} else {
 			log.Printf("[Report] Payload length: %d bytes\n", len(jsonData))
@@ -159,13 +284,55 @@ func sendHTTPRequest(_url url.URL, payload ReportData, client *http.Client) {
 	reportUrl, err := url.JoinPath(_url.String(), "/serverStatus/report")
 	if err != nil {
 		log.Println("Join url error:", err)
-		return
+		return &ReportError{Err: err}
 	}
 
-	req, err := http.NewRequest("POST", reportUrl, bytes.NewBuffer(jsonData))
+	for attempt := 1; ; attempt++ {
+		// A server which accepts the connection but never answers must not
+		// hold up the report forever
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		err = postReport(attemptCtx, reportUrl, jsonData, client)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) {
+			log.Println("Report rejected, will not retry:", err)
+			return err
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			log.Printf("Send request error after %d attempts: %s\n", attempt, err)
+			return err
+		}
+
+		backoff := policy.Backoff(attempt)
+		if retryAfter := retryAfter(err); retryAfter > 0 {
+			// Give up early instead of retrying before the server is ready,
+			// the caller keeps the report until then
+			if policy.MaxBackoff > 0 && retryAfter > policy.MaxBackoff {
+				log.Printf("Send request error: %s, server asked to retry in %s\n", err, retryAfter.Round(time.Second))
+				return err
+			}
+			backoff = retryAfter
+		}
+
+		log.Printf("Send request error: %s, retry in %s\n", err, backoff.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func postReport(ctx context.Context, reportUrl string, jsonData []byte, client *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", reportUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Println("Create request error:", err)
-		return
+		return &ReportError{Err: err}
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -173,8 +340,7 @@ func sendHTTPRequest(_url url.URL, payload ReportData, client *http.Client) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Send request error:", err)
-		return
+		return err
 	}
 
 	defer resp.Body.Close()
@@ -183,11 +349,16 @@ func sendHTTPRequest(_url url.URL, payload ReportData, client *http.Client) {
 	body := new(bytes.Buffer)
 	_, err = body.ReadFrom(resp.Body)
 	if err != nil {
-		log.Println("Read response error:", err)
-		return
+		return err
+	}
+
+	if err := newResponseError(resp, body.String()); err != nil {
+		return err
 	}
 
-	if !*Silent {
+	if !config.Silent {
 		log.Println("Response:", body)
 	}
+
+	return nil
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/**
+ * RetryPolicy decides how many times a failed report is sent again and how
+ * long to wait in between, the wait grows exponentially with random jitter
+ */
+type RetryPolicy struct {
+	MaxAttempts    int
+	AttemptTimeout time.Duration // 0 means an attempt can wait forever
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64 // 0-1, the fraction of the backoff which is randomized
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	AttemptTimeout: 10 * time.Second,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+// Backoff returns the wait before the next attempt, attempt starts from 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	backoff -= backoff * jitter * rand.Float64()
+
+	return time.Duration(backoff)
+}
+
+/**
+ * ReportError is returned when the server did not accept the report
+ */
+type ReportError struct {
+	StatusCode int
+	Body       string
+	Retryable  bool
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ReportError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("server responded %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *ReportError) Unwrap() error {
+	return e.Err
+}
+
+// isRetryable reports whether sending the report again may succeed, network
+// errors are retryable, rejected payloads are not.
+func isRetryable(err error) bool {
+	var reportErr *ReportError
+	if errors.As(err, &reportErr) {
+		return reportErr.Retryable
+	}
+	return true
+}
+
+// retryAfter returns the wait the server asked for before the next attempt
+func retryAfter(err error) time.Duration {
+	var reportErr *ReportError
+	if errors.As(err, &reportErr) {
+		return reportErr.RetryAfter
+	}
+	return 0
+}
+
+func newResponseError(resp *http.Response, body string) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+
+	reportErr := &ReportError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Retryable:  resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests,
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		reportErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+
+	return reportErr
+}
+
+/**
+ * Parse Retry-After header, which can be delay seconds or a http date
+ */
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
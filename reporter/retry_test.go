@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     50 * time.Millisecond,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, attempt int32)) (*url.URL, *int32) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/serverStatus/report", r.URL.Path)
+		handler(w, atomic.AddInt32(&attempts, 1))
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	return serverURL, &attempts
+}
+
+func TestSendHTTPRequestRetryServerError(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("success"))
+	})
+
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, testRetryPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), *attempts)
+}
+
+func TestSendHTTPRequestGiveUp(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, testRetryPolicy)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, int32(3), *attempts)
+}
+
+func TestSendHTTPRequestNoRetryOnValidationError(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"workspaceId is required"}`))
+	})
+
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, testRetryPolicy)
+	assert.Error(t, err)
+	assert.False(t, isRetryable(err))
+	assert.Contains(t, err.Error(), "workspaceId is required")
+	assert.Equal(t, int32(1), *attempts)
+}
+
+func TestSendHTTPRequestRetryAfter(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("success"))
+	})
+
+	policy := testRetryPolicy
+	policy.MaxBackoff = 5 * time.Second
+
+	start := time.Now()
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *attempts)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Less(t, time.Since(start), policy.MaxBackoff)
+}
+
+func TestSendHTTPRequestRetryAfterExceedsMaxBackoff(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	start := time.Now()
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, testRetryPolicy)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, 120*time.Second, retryAfter(err))
+	assert.Equal(t, int32(1), *attempts)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestSendHTTPRequestAttemptTimeout(t *testing.T) {
+	var attempts int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		// Accept the request but never answer
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	policy := testRetryPolicy
+	policy.AttemptTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err = sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, policy)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSendHTTPRequestNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL, _ := url.Parse(server.URL)
+	server.Close()
+
+	err := sendHTTPRequest(context.Background(), *serverURL, ReportData{}, http.DefaultClient, testRetryPolicy)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		assert.GreaterOrEqual(t, backoff, 500*time.Millisecond)
+		assert.LessOrEqual(t, backoff, time.Second)
+
+		backoff = policy.Backoff(3)
+		assert.GreaterOrEqual(t, backoff, 2*time.Second)
+		assert.LessOrEqual(t, backoff, 4*time.Second)
+
+		backoff = policy.Backoff(10)
+		assert.GreaterOrEqual(t, backoff, 5*time.Second)
+		assert.LessOrEqual(t, backoff, 10*time.Second)
+	}
+
+	policy.Jitter = 0
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	delay := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, delay, 50*time.Second)
+	assert.LessOrEqual(t, delay, time.Minute)
+}
+
+func TestSendHTTPRequestCanceled(t *testing.T) {
+	serverURL, attempts := newTestServer(t, func(w http.ResponseWriter, attempt int32) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := testRetryPolicy
+	policy.MaxAttempts = 10
+	policy.InitialBackoff = time.Minute
+	policy.MaxBackoff = time.Minute
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := sendHTTPRequest(ctx, *serverURL, ReportData{}, http.DefaultClient, policy)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), *attempts)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolFileExt = ".json"
+
+// The max number of failed replays before a queued report is dropped, so a
+// report the server keeps failing on can not hold up the rest of the queue
+const spoolMaxAttempts = 5
+
+/**
+ * Spool is a bounded on-disk queue for reports which failed to send.
+ * Every report is stored in its own file, named after the time it was
+ * collected and the number of failed replays, so both the replay order and
+ * the attempts survive a restart of the reporter.
+ */
+type Spool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+type spoolEntry struct {
+	path      string
+	size      int64
+	timestamp int64
+	seq       string
+	attempts  int
+}
+
+func NewSpool(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+	}, nil
+}
+
+func defaultSpoolDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "tianji-reporter", "spool")
+}
+
+// Push stores a report at the end of the queue and drops the oldest
+// entries once the size limit is exceeded.
+func (s *Spool) Push(data ReportData) error {
+	jsonData, err := jsoniter.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	name := spoolFileName(data.Timestamp, fmt.Sprintf("%010d", s.seq), 0)
+	tmpPath := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmpPath, jsonData, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return s.trim()
+}
+
+// Replay sends the newest limit queued reports, newest first, removing each
+// one once it is delivered, and drops the older ones. It stops at the first
+// retryable failure so the remaining reports are kept for the next attempt,
+// unless the failing report has used up its attempts, in which case it is
+// dropped.
+func (s *Spool) Replay(send func(ReportData) error, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	slices.Reverse(entries)
+	if limit > 0 && len(entries) > limit {
+		for _, entry := range entries[limit:] {
+			os.Remove(entry.path)
+		}
+		log.Printf("Drop %d spooled reports older than the replay window\n", len(entries)-limit)
+		entries = entries[:limit]
+	}
+
+	sent := 0
+	for _, entry := range entries {
+		if s.expired(entry) {
+			os.Remove(entry.path)
+			continue
+		}
+
+		buf, err := os.ReadFile(entry.path)
+		if err != nil {
+			return sent, err
+		}
+		var data ReportData
+		if err := jsoniter.Unmarshal(buf, &data); err != nil {
+			// Corrupted entry, it will never be sent successfully
+			os.Remove(entry.path)
+			continue
+		}
+
+		data.Replay = true
+		if err := send(data); err != nil {
+			if !isRetryable(err) {
+				// Rejected by the server, retrying will not help
+				os.Remove(entry.path)
+				continue
+			}
+			if entry.attempts+1 >= spoolMaxAttempts {
+				log.Printf("Drop spooled report after %d failed attempts: %s\n", spoolMaxAttempts, err)
+				os.Remove(entry.path)
+				continue
+			}
+			name := spoolFileName(entry.timestamp, entry.seq, entry.attempts+1)
+			os.Rename(entry.path, filepath.Join(s.dir, name))
+			return sent, err
+		}
+		os.Remove(entry.path)
+		sent++
+	}
+
+	return sent, nil
+}
+
+// Len returns the number of queued reports.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, _ := s.entries()
+	return len(entries)
+}
+
+func (s *Spool) trim() error {
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	kept := entries[:0]
+	for _, entry := range entries {
+		if s.expired(entry) {
+			os.Remove(entry.path)
+			continue
+		}
+		total += entry.size
+		kept = append(kept, entry)
+	}
+
+	for i := 0; s.maxBytes > 0 && total > s.maxBytes && i < len(kept); i++ {
+		os.Remove(kept[i].path)
+		total -= kept[i].size
+	}
+
+	return nil
+}
+
+func (s *Spool) expired(entry spoolEntry) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+	return time.Since(time.UnixMilli(entry.timestamp)) > s.maxAge
+}
+
+func (s *Spool) entries() ([]spoolEntry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]spoolEntry, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, spoolFileExt) {
+			continue
+		}
+		// <timestamp>-<seq>-<attempts>.json
+		parts := strings.Split(strings.TrimSuffix(name, spoolFileExt), "-")
+		if len(parts) != 3 {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		attempts, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, spoolEntry{
+			path:      filepath.Join(s.dir, name),
+			size:      info.Size(),
+			timestamp: timestamp,
+			seq:       parts[1],
+			attempts:  attempts,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return filepath.Base(entries[i].path) < filepath.Base(entries[j].path)
+	})
+
+	return entries, nil
+}
+
+func spoolFileName(timestamp int64, seq string, attempts int) string {
+	return fmt.Sprintf("%020d-%s-%d%s", timestamp, seq, attempts, spoolFileExt)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestReport(name string, timestamp time.Time) ReportData {
+	return ReportData{
+		WorkspaceId: "workspace",
+		Name:        name,
+		Hostname:    name,
+		Timeout:     50,
+		Timestamp:   timestamp.UnixMilli(),
+	}
+}
+
+func TestSpoolReplayNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewSpool(dir, 1024*1024, time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.NoError(t, spool.Push(newTestReport("b", now.Add(-time.Minute))))
+	assert.NoError(t, spool.Push(newTestReport("c", now)))
+	assert.NoError(t, spool.Push(newTestReport("a", now.Add(-2*time.Minute))))
+
+	// Reopen the spool to simulate a restart of the reporter
+	spool, err = NewSpool(dir, 1024*1024, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, spool.Len())
+
+	var names []string
+	var timestamps []int64
+	sent, err := spool.Replay(func(data ReportData) error {
+		assert.True(t, data.Replay)
+		names = append(names, data.Name)
+		timestamps = append(timestamps, data.Timestamp)
+		return nil
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+	assert.Equal(t, now.Add(-2*time.Minute).UnixMilli(), timestamps[2])
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestSpoolReplayStopsOnError(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 1024*1024, time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for _, name := range []string{"a", "b", "c"} {
+		now = now.Add(time.Second)
+		assert.NoError(t, spool.Push(newTestReport(name, now)))
+	}
+
+	sent, err := spool.Replay(func(data ReportData) error {
+		if data.Name == "b" {
+			return errors.New("network is unreachable")
+		}
+		return nil
+	}, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 2, spool.Len())
+
+	var names []string
+	sent, err = spool.Replay(func(data ReportData) error {
+		names = append(names, data.Name)
+		return nil
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"b", "a"}, names)
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestSpoolReplayWindow(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 1024*1024, time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		now = now.Add(time.Second)
+		assert.NoError(t, spool.Push(newTestReport(name, now)))
+	}
+
+	// Only the newest reports are replayed, the older ones are dropped
+	var names []string
+	sent, err := spool.Replay(func(data ReportData) error {
+		names = append(names, data.Name)
+		return nil
+	}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"d", "c"}, names)
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestSpoolLimits(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 0, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, spool.Push(newTestReport("expired", time.Now().Add(-2*time.Hour))))
+	assert.NoError(t, spool.Push(newTestReport("fresh", time.Now())))
+	assert.Equal(t, 1, spool.Len())
+
+	report := newTestReport("fresh", time.Now())
+	spool, err = NewSpool(t.TempDir(), 1, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, spool.Push(report))
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestSpoolReplayDropsAfterMaxAttempts(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewSpool(dir, 1024*1024, time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.NoError(t, spool.Push(newTestReport("good", now)))
+	assert.NoError(t, spool.Push(newTestReport("bad", now.Add(time.Second))))
+
+	send := func(data ReportData) error {
+		if data.Name == "bad" {
+			return errors.New("network is unreachable")
+		}
+		return nil
+	}
+	for i := 1; i < spoolMaxAttempts; i++ {
+		// Reopen the spool, the attempts must survive a restart
+		spool, err = NewSpool(dir, 1024*1024, time.Hour)
+		assert.NoError(t, err)
+
+		sent, err := spool.Replay(send, 0)
+		assert.Error(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Equal(t, 2, spool.Len())
+	}
+
+	sent, err := spool.Replay(send, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 0, spool.Len())
+}
+
+func TestDeliverSendsLiveReportFirst(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 1024*1024, time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.NoError(t, spool.Push(newTestReport("queued", now.Add(-time.Minute))))
+
+	// The backlog is kept while the server is down
+	var names []string
+	deliver(func(data ReportData) error {
+		names = append(names, data.Name)
+		return errors.New("network is unreachable")
+	}, spool, newTestReport("live", now))
+	assert.Equal(t, []string{"live"}, names)
+	assert.Equal(t, 2, spool.Len())
+
+	// A failing queued report does not stop the live report
+	names = nil
+	deliver(func(data ReportData) error {
+		names = append(names, data.Name)
+		if data.Name == "queued" {
+			return errors.New("network is unreachable")
+		}
+		return nil
+	}, spool, newTestReport("next", now.Add(time.Minute)))
+	assert.Equal(t, []string{"next", "live", "queued"}, names)
+	assert.Equal(t, 1, spool.Len())
+
+	names = nil
+	deliver(func(data ReportData) error {
+		names = append(names, data.Name)
+		return nil
+	}, spool, newTestReport("last", now.Add(2*time.Minute)))
+	assert.Equal(t, []string{"last", "queued"}, names)
+	assert.Equal(t, 0, spool.Len())
+}
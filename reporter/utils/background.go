@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/**
+ * BackgroundCollector runs a slow collector on its own schedule, e.g.
+ * network probes which can wait for seconds. Collect only returns the latest
+ * result, so the report is never delayed by it. Nothing is collected before
+ * Start is called.
+ */
+type BackgroundCollector struct {
+	collector Collector
+	options   CollectorOptions
+
+	mu    sync.Mutex
+	merge MergeFunc
+	err   error
+}
+
+func NewBackgroundCollector(collector Collector, options CollectorOptions) *BackgroundCollector {
+	return &BackgroundCollector{
+		collector: collector,
+		options:   options,
+	}
+}
+
+func (b *BackgroundCollector) Name() string {
+	return b.collector.Name()
+}
+
+func (b *BackgroundCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.merge, b.err
+}
+
+// Start collects at once and then on every interval until ctx is done
+func (b *BackgroundCollector) Start(ctx context.Context) {
+	interval := time.Duration(b.options.Interval)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			b.run(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (b *BackgroundCollector) run(ctx context.Context) {
+	if b.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(b.options.Timeout))
+		defer cancel()
+	}
+
+	merge, err := b.collector.Collect(ctx)
+
+	b.mu.Lock()
+	b.merge = merge
+	b.err = err
+	b.mu.Unlock()
+}
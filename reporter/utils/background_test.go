@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type countingCollector struct {
+	calls chan int
+	count int
+}
+
+func (c *countingCollector) Name() string {
+	return "counting"
+}
+
+func (c *countingCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	c.count++
+	count := c.count
+	defer func() { c.calls <- count }()
+	return func(payload *ReportDataPayload) {
+		payload.Uptime = uint64(count)
+	}, nil
+}
+
+func TestBackgroundCollector(t *testing.T) {
+	inner := &countingCollector{calls: make(chan int, 10)}
+	collector := NewBackgroundCollector(inner, CollectorOptions{Interval: Duration(20 * time.Millisecond)})
+	assert.Equal(t, "counting", collector.Name())
+
+	// Nothing before it is started
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+
+	registry := NewRegistry()
+	registry.Register(collector, CollectorOptions{Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	registry.Start(ctx)
+	// The result of a call is stored before the next call starts
+	<-inner.calls
+	<-inner.calls
+	<-inner.calls
+	cancel()
+
+	payload := registry.Collect(context.Background())
+	assert.GreaterOrEqual(t, payload.Uptime, uint64(2))
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/**
+ * CertificatesConfig lists the certificates to watch, Files are PEM files
+ * or globs like /etc/nginx/ssl/*.pem, Endpoints are host:port pairs which
+ * are checked by a TLS handshake
+ */
+type CertificatesConfig struct {
+	CollectorOptions
+	Files     []string `json:"files"`
+	Endpoints []string `json:"endpoints"`
+}
+
+/**
+ * CertificateInfo is the leaf certificate of a file or endpoint. Valid tells
+ * whether the chain is trusted by the system roots, Error holds the reason
+ * when it is not or when the certificate could not be read.
+ */
+type CertificateInfo struct {
+	Source    string   `json:"source"`
+	Subject   string   `json:"subject,omitempty"`
+	Issuer    string   `json:"issuer,omitempty"`
+	SANs      []string `json:"sans,omitempty"`
+	NotBefore int64    `json:"not_before,omitempty"` // unix seconds
+	NotAfter  int64    `json:"not_after,omitempty"`  // unix seconds
+	DaysLeft  int      `json:"days_left"`
+	Valid     bool     `json:"valid"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type certificateCollector struct {
+	config CertificatesConfig
+	roots  *x509.CertPool // nil means the system roots
+}
+
+func (c *certificateCollector) Name() string {
+	return "certificates"
+}
+
+func (c *certificateCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	var files []string
+	for _, pattern := range c.config.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			// Report the missing file instead of dropping it silently
+			files = append(files, pattern)
+			continue
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 && len(c.config.Endpoints) == 0 {
+		return nil, nil
+	}
+
+	result := make([]CertificateInfo, len(files)+len(c.config.Endpoints))
+	for i, file := range files {
+		result[i] = c.checkFile(file)
+	}
+
+	var wg sync.WaitGroup
+	for i, endpoint := range c.config.Endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			result[i] = c.checkEndpoint(ctx, endpoint)
+		}(len(files)+i, endpoint)
+	}
+	wg.Wait()
+
+	return func(payload *ReportDataPayload) {
+		payload.Certificates = result
+	}, nil
+}
+
+func (c *certificateCollector) checkFile(path string) CertificateInfo {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return CertificateInfo{Source: path, Error: err.Error()}
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		// Skip keys in combined PEM files
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return CertificateInfo{Source: path, Error: err.Error()}
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return CertificateInfo{Source: path, Error: "no certificate found"}
+	}
+
+	return c.describe(path, certs, "")
+}
+
+func (c *certificateCollector) checkEndpoint(ctx context.Context, endpoint string) CertificateInfo {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return CertificateInfo{Source: endpoint, Error: err.Error()}
+	}
+
+	// Verify by hand after the handshake, an untrusted chain should still be reported
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return CertificateInfo{Source: endpoint, Error: err.Error()}
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return CertificateInfo{Source: endpoint, Error: "no certificate found"}
+	}
+	return c.describe(endpoint, certs, host)
+}
+
+// describe the leaf of certs, the others are used as intermediates
+func (c *certificateCollector) describe(source string, certs []*x509.Certificate, host string) CertificateInfo {
+	leaf := certs[0]
+	info := CertificateInfo{
+		Source:    source,
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		NotBefore: leaf.NotBefore.Unix(),
+		NotAfter:  leaf.NotAfter.Unix(),
+		DaysLeft:  int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24)),
+	}
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         c.roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Valid = true
+	return info
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func newTestCA(t *testing.T) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func newTestLeaf(t *testing.T, ca *testCert, notAfter time.Time) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com", "localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+}
+
+func writePEM(t *testing.T, path string, certs ...*testCert) {
+	var buf []byte
+	for _, cert := range certs {
+		buf = append(buf, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.der})...)
+	}
+	// A key in the same file is skipped
+	key, err := x509.MarshalECPrivateKey(certs[0].key)
+	assert.NoError(t, err)
+	buf = append(buf, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})...)
+	assert.NoError(t, os.WriteFile(path, buf, 0600))
+}
+
+func collectTestCertificates(t *testing.T, collector *certificateCollector) []CertificateInfo {
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	payload := ReportDataPayload{}
+	merge(&payload)
+	return payload.Certificates
+}
+
+func TestCertificateCollectorFiles(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	writePEM(t, filepath.Join(dir, "site.pem"), newTestLeaf(t, ca, time.Now().Add(30*24*time.Hour+time.Hour)))
+	writePEM(t, filepath.Join(dir, "expired.pem"), newTestLeaf(t, ca, time.Now().Add(-48*time.Hour-time.Hour)))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.crt"), []byte("not a certificate"), 0600))
+
+	collector := &certificateCollector{
+		config: CertificatesConfig{Files: []string{
+			filepath.Join(dir, "*.pem"),
+			filepath.Join(dir, "broken.crt"),
+			filepath.Join(dir, "missing.pem"),
+		}},
+		roots: roots,
+	}
+	certs := collectTestCertificates(t, collector)
+	assert.Len(t, certs, 4)
+
+	expired := certs[0]
+	assert.Equal(t, filepath.Join(dir, "expired.pem"), expired.Source)
+	assert.Equal(t, -3, expired.DaysLeft)
+	assert.False(t, expired.Valid)
+	assert.Contains(t, expired.Error, "expired")
+
+	site := certs[1]
+	assert.Equal(t, "CN=example.com", site.Subject)
+	assert.Equal(t, "CN=Test CA", site.Issuer)
+	assert.Equal(t, []string{"example.com", "localhost", "127.0.0.1"}, site.SANs)
+	assert.Equal(t, 30, site.DaysLeft)
+	assert.True(t, site.Valid)
+	assert.Empty(t, site.Error)
+
+	assert.Equal(t, "no certificate found", certs[2].Error)
+	assert.Equal(t, filepath.Join(dir, "missing.pem"), certs[3].Source)
+	assert.NotEmpty(t, certs[3].Error)
+
+	// Not trusted by the system roots
+	collector.roots = nil
+	certs = collectTestCertificates(t, collector)
+	assert.False(t, certs[1].Valid)
+	assert.NotEmpty(t, certs[1].Error)
+}
+
+func TestCertificateCollectorEndpoint(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	leaf := newTestLeaf(t, ca, time.Now().Add(10*24*time.Hour+time.Hour))
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.der, ca.der},
+			PrivateKey:  leaf.key,
+		}},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	collector := &certificateCollector{
+		config: CertificatesConfig{Endpoints: []string{
+			net.JoinHostPort("localhost", port),
+			closedAddress(t),
+		}},
+		roots: roots,
+	}
+	certs := collectTestCertificates(t, collector)
+	assert.Len(t, certs, 2)
+
+	assert.Equal(t, "CN=example.com", certs[0].Subject)
+	assert.Equal(t, 10, certs[0].DaysLeft)
+	assert.True(t, certs[0].Valid, certs[0].Error)
+
+	assert.Empty(t, certs[1].Subject)
+	assert.Contains(t, certs[1].Error, "refused")
+}
+
+func TestCertificateCollectorEmpty(t *testing.T) {
+	merge, err := (&certificateCollector{}).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+}
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * Collector gathers one group of metrics, e.g. memory or docker containers.
+ * Collect returns a MergeFunc which writes the result into the payload, so the
+ * registry can reuse the last result when the collector is not due yet.
+ * A collector may return both a MergeFunc and an error for partial results.
+ */
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) (MergeFunc, error)
+}
+
+type MergeFunc func(payload *ReportDataPayload)
+
+type CollectorError struct {
+	Name     string `json:"name"`
+	Error    string `json:"error"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+type CollectorOptions struct {
+	Enabled  bool     `json:"enabled"`
+	Timeout  Duration `json:"timeout"`  // 0 means no timeout
+	Interval Duration `json:"interval"` // 0 means collect on every report
+}
+
+type collectorFunc struct {
+	name    string
+	collect func(ctx context.Context) (MergeFunc, error)
+}
+
+func (c collectorFunc) Name() string {
+	return c.name
+}
+
+func (c collectorFunc) Collect(ctx context.Context) (MergeFunc, error) {
+	return c.collect(ctx)
+}
+
+// NewCollector creates a stateless collector from a function
+func NewCollector(name string, collect func(ctx context.Context) (MergeFunc, error)) Collector {
+	return collectorFunc{name: name, collect: collect}
+}
+
+type registryEntry struct {
+	collector Collector
+	options   CollectorOptions
+	lastRun   time.Time
+	last      MergeFunc
+	err       error
+	timedOut  bool
+	logged    string
+	// Set while Collect is running, it may outlive a timed out collection
+	running atomic.Bool
+}
+
+/**
+ * Registry runs the registered collectors concurrently and merges their
+ * output into a payload. Each collector runs under its own timeout, a slow
+ * one is skipped and recorded in the payload instead of delaying the report.
+ */
+type Registry struct {
+	entries []*registryEntry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(collector Collector, options CollectorOptions) {
+	r.entries = append(r.entries, &registryEntry{
+		collector: collector,
+		options:   options,
+	})
+}
+
+// Names returns the names of enabled collectors
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if entry.options.Enabled {
+			names = append(names, entry.collector.Name())
+		}
+	}
+	return names
+}
+
+// Start starts the collectors which run in the background, see BackgroundCollector
+func (r *Registry) Start(ctx context.Context) {
+	for _, entry := range r.entries {
+		if !entry.options.Enabled {
+			continue
+		}
+		if background, ok := entry.collector.(*BackgroundCollector); ok {
+			background.Start(ctx)
+		}
+	}
+}
+
+// Collect must not be called concurrently
+func (r *Registry) Collect(ctx context.Context) ReportDataPayload {
+	payload := ReportDataPayload{}
+
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, entry := range r.entries {
+		if !entry.options.Enabled || !entry.due(now) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(entry *registryEntry) {
+			defer wg.Done()
+			entry.run(ctx, now)
+		}(entry)
+	}
+	wg.Wait()
+
+	// Merge in registration order so the result does not depend on timing
+	for _, entry := range r.entries {
+		if !entry.options.Enabled {
+			continue
+		}
+
+		if entry.last != nil {
+			entry.last(&payload)
+		}
+		if entry.err != nil {
+			payload.CollectorErrors = append(payload.CollectorErrors, CollectorError{
+				Name:     entry.collector.Name(),
+				Error:    entry.err.Error(),
+				TimedOut: entry.timedOut,
+			})
+		}
+	}
+
+	return payload
+}
+
+func (e *registryEntry) due(now time.Time) bool {
+	if e.options.Interval <= 0 || e.lastRun.IsZero() {
+		return true
+	}
+	return now.Sub(e.lastRun) >= time.Duration(e.options.Interval)
+}
+
+type collectResult struct {
+	merge MergeFunc
+	err   error
+}
+
+func (e *registryEntry) run(ctx context.Context, now time.Time) {
+	e.lastRun = now
+	e.last = nil
+
+	if !e.running.CompareAndSwap(false, true) {
+		// The collection of an earlier report is still hanging
+		e.err = errors.New("still running from an earlier report")
+		e.timedOut = true
+		e.log()
+		return
+	}
+
+	if e.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(e.options.Timeout))
+		defer cancel()
+	}
+
+	done := make(chan collectResult, 1)
+	go func() {
+		defer e.running.Store(false)
+		merge, err := e.collector.Collect(ctx)
+		done <- collectResult{merge: merge, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		e.last = result.merge
+		e.err = result.err
+	case <-ctx.Done():
+		// Give up on collectors which ignore the context
+		e.err = ctx.Err()
+	}
+	e.timedOut = errors.Is(e.err, context.DeadlineExceeded)
+	e.log()
+}
+
+// Only log when the error changes, a broken collector fails on every report
+func (e *registryEntry) log() {
+	errMsg := ""
+	if e.err != nil {
+		errMsg = e.err.Error()
+	}
+	if errMsg == e.logged {
+		return
+	}
+
+	if e.err != nil {
+		log.Printf("[Collector] %s error: %s\n", e.collector.Name(), e.err)
+	} else {
+		log.Printf("[Collector] %s recovered\n", e.collector.Name())
+	}
+	e.logged = errMsg
+}
+
+/**
+ * CollectorsConfig enables and tunes the built-in collectors
+ */
+type CollectorsConfig struct {
+	Host         CollectorOptions   `json:"host"`
+	Inventory    CollectorOptions   `json:"inventory"`
+	CPU          CPUConfig          `json:"cpu"`
+	Load         CollectorOptions   `json:"load"`
+	Memory       CollectorOptions   `json:"memory"`
+	Disk         DiskConfig         `json:"disk"`
+	DiskIO       DiskIOConfig       `json:"diskio"`
+	Sensors      CollectorOptions   `json:"sensors"`
+	Traffic      TrafficConfig      `json:"traffic"`
+	Connections  CollectorOptions   `json:"connections"`
+	Connectivity ConnectivityConfig `json:"connectivity"`
+	Probes       ProbesConfig       `json:"probes"`
+	Certificates CertificatesConfig `json:"certificates"`
+	Systemd      SystemdConfig      `json:"systemd"`
+	Docker       CollectorOptions   `json:"docker"`
+	Processes    ProcessesConfig    `json:"processes"`
+}
+
+func DefaultCollectorsConfig() CollectorsConfig {
+	enabled := CollectorOptions{
+		Enabled: true,
+		Timeout: Duration(10 * time.Second),
+	}
+
+	// Only sent once per interval, no need to read it on every report
+	inventory := enabled
+	inventory.Interval = Duration(10 * time.Minute)
+
+	// Scanning the sockets of every process is expensive, once a minute
+	// is enough to follow the connection counts
+	connections := enabled
+	connections.Interval = Duration(time.Minute)
+
+	// Background collectors run on their own interval
+	background := enabled
+	background.Interval = Duration(time.Minute)
+
+	// No targets by default, nothing is dialed until they are configured
+	connectivity := ConnectivityConfig{CollectorOptions: background}
+	connectivity.Timeout = Duration(5 * time.Second)
+
+	// Certificates last for months, once an hour is plenty
+	certificates := background
+	certificates.Interval = Duration(time.Hour)
+
+	return CollectorsConfig{
+		Host:         enabled,
+		Inventory:    inventory,
+		CPU:          CPUConfig{CollectorOptions: enabled},
+		Load:         enabled,
+		Memory:       enabled,
+		Disk:         DiskConfig{CollectorOptions: enabled},
+		DiskIO:       DiskIOConfig{CollectorOptions: enabled},
+		Sensors:      enabled,
+		Traffic:      TrafficConfig{CollectorOptions: enabled},
+		Connections:  connections,
+		Connectivity: connectivity,
+		Probes:       ProbesConfig{CollectorOptions: background},
+		Certificates: CertificatesConfig{CollectorOptions: certificates},
+		Systemd:      SystemdConfig{CollectorOptions: enabled},
+		Docker:       enabled,
+		Processes:    ProcessesConfig{CollectorOptions: enabled, Top: 3},
+	}
+}
+
+// NewDefaultRegistry creates a registry with all built-in collectors
+func NewDefaultRegistry(config CollectorsConfig) *Registry {
+	r := NewRegistry()
+	r.Register(NewCollector("host", collectHost), config.Host)
+	r.Register(newInventoryCollector(), config.Inventory)
+	r.Register(&cpuCollector{detail: config.CPU.Detail}, config.CPU.CollectorOptions)
+	r.Register(NewCollector("load", collectLoad), config.Load)
+	r.Register(&memoryCollector{}, config.Memory)
+	r.Register(&diskCollector{config: config.Disk}, config.Disk.CollectorOptions)
+	r.Register(&diskIOCollector{config: config.DiskIO}, config.DiskIO.CollectorOptions)
+	r.Register(NewCollector("sensors", collectSensors), config.Sensors)
+	r.Register(newTrafficCollector(config.Traffic), config.Traffic.CollectorOptions)
+	r.Register(NewCollector("connections", collectConnections), config.Connections)
+	// Dials can take seconds, the registry only picks up the latest result
+	r.Register(
+		NewBackgroundCollector(newConnectivityCollector(config.Connectivity), config.Connectivity.CollectorOptions),
+		CollectorOptions{Enabled: config.Connectivity.Enabled},
+	)
+	r.Register(
+		NewBackgroundCollector(newProbeCollector(config.Probes), config.Probes.CollectorOptions),
+		CollectorOptions{Enabled: config.Probes.Enabled},
+	)
+	r.Register(
+		NewBackgroundCollector(&certificateCollector{config: config.Certificates}, config.Certificates.CollectorOptions),
+		CollectorOptions{Enabled: config.Certificates.Enabled},
+	)
+	r.Register(newSystemdCollector(config.Systemd), config.Systemd.CollectorOptions)
+	r.Register(NewCollector("docker", collectDocker), config.Docker)
+	r.Register(newProcessCollector(config.Processes), config.Processes.CollectorOptions)
+	return r
+}
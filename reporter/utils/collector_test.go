@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newCountingCollector(name string, calls *int, uptime uint64) Collector {
+	return NewCollector(name, func(ctx context.Context) (MergeFunc, error) {
+		*calls++
+		return func(payload *ReportDataPayload) {
+			payload.Uptime = uptime
+		}, nil
+	})
+}
+
+func TestRegistryCollect(t *testing.T) {
+	var enabledCalls, disabledCalls int
+	registry := NewRegistry()
+	registry.Register(newCountingCollector("enabled", &enabledCalls, 10), CollectorOptions{Enabled: true})
+	registry.Register(newCountingCollector("disabled", &disabledCalls, 20), CollectorOptions{Enabled: false})
+
+	payload := registry.Collect(context.Background())
+	assert.Equal(t, uint64(10), payload.Uptime)
+	assert.Equal(t, 1, enabledCalls)
+	assert.Equal(t, 0, disabledCalls)
+	assert.Equal(t, []string{"enabled"}, registry.Names())
+}
+
+func TestRegistryInterval(t *testing.T) {
+	var calls int
+	registry := NewRegistry()
+	registry.Register(newCountingCollector("slow", &calls, 10), CollectorOptions{
+		Enabled:  true,
+		Interval: Duration(time.Hour),
+	})
+
+	registry.Collect(context.Background())
+	payload := registry.Collect(context.Background())
+
+	// The last result is reused until the collector is due again
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, uint64(10), payload.Uptime)
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	registry := NewRegistry()
+	registry.Register(NewCollector("hang", func(ctx context.Context) (MergeFunc, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), CollectorOptions{Enabled: true, Timeout: Duration(10 * time.Millisecond)})
+	// Ignores the context, e.g. a blocking syscall
+	registry.Register(NewCollector("stuck", func(ctx context.Context) (MergeFunc, error) {
+		<-release
+		return nil, nil
+	}), CollectorOptions{Enabled: true, Timeout: Duration(10 * time.Millisecond)})
+	registry.Register(newCountingCollector("ok", new(int), 10), CollectorOptions{Enabled: true})
+
+	start := time.Now()
+	payload := registry.Collect(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, uint64(10), payload.Uptime)
+	assert.Len(t, payload.CollectorErrors, 2)
+	assert.Equal(t, "hang", payload.CollectorErrors[0].Name)
+	assert.True(t, payload.CollectorErrors[0].TimedOut)
+	assert.Equal(t, "stuck", payload.CollectorErrors[1].Name)
+	assert.True(t, payload.CollectorErrors[1].TimedOut)
+
+	// The stuck collector is not started again while the last one still hangs
+	payload = registry.Collect(context.Background())
+	assert.Len(t, payload.CollectorErrors, 2)
+	assert.Contains(t, payload.CollectorErrors[1].Error, "still running")
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	registry := NewRegistry()
+	for _, name := range []string{"a", "b", "c"} {
+		registry.Register(NewCollector(name, func(ctx context.Context) (MergeFunc, error) {
+			time.Sleep(100 * time.Millisecond)
+			return nil, nil
+		}), CollectorOptions{Enabled: true})
+	}
+
+	start := time.Now()
+	payload := registry.Collect(context.Background())
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+	assert.Empty(t, payload.CollectorErrors)
+}
+
+func TestRegistryPartialResult(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCollector("partial", func(ctx context.Context) (MergeFunc, error) {
+		return func(payload *ReportDataPayload) {
+			payload.NetworkRx = 100
+		}, errors.New("vnstat not found")
+	}), CollectorOptions{Enabled: true})
+
+	payload := registry.Collect(context.Background())
+	assert.Equal(t, uint64(100), payload.NetworkRx)
+	assert.Equal(t, []CollectorError{{Name: "partial", Error: "vnstat not found"}}, payload.CollectorErrors)
+}
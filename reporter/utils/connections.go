@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	pNet "github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+	"sort"
+	"syscall"
+)
+
+/**
+ * ConnectionStats summarises the sockets of the host, e.g. a pile of
+ * TIME_WAIT means the ephemeral ports are about to run out
+ */
+type ConnectionStats struct {
+	TCP       map[string]int  `json:"tcp"` // count by state, ipv4 and ipv6
+	UDP       int             `json:"udp"`
+	Listening []ListeningPort `json:"listening,omitempty"`
+}
+
+type ListeningPort struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp or udp6
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	PID      int32  `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+}
+
+func collectConnections(ctx context.Context) (MergeFunc, error) {
+	conns, err := pNet.ConnectionsWithoutUidsWithContext(ctx, "inet")
+	if err != nil {
+		return nil, err
+	}
+
+	// Owners of other users' sockets are unknown without root, pid is 0 then
+	names := make(map[int32]string)
+	processName := func(pid int32) string {
+		if name, ok := names[pid]; ok {
+			return name
+		}
+		name := ""
+		if p, err := process.NewProcessWithContext(ctx, pid); err == nil {
+			name, _ = p.NameWithContext(ctx)
+		}
+		names[pid] = name
+		return name
+	}
+
+	stats := summarizeConnections(conns, processName)
+	return func(payload *ReportDataPayload) {
+		payload.Connections = stats
+	}, nil
+}
+
+func summarizeConnections(conns []pNet.ConnectionStat, processName func(pid int32) string) *ConnectionStats {
+	stats := &ConnectionStats{
+		TCP: make(map[string]int),
+	}
+
+	seen := make(map[ListeningPort]bool)
+	for _, conn := range conns {
+		protocol := connectionProtocol(conn)
+		switch conn.Type {
+		case syscall.SOCK_STREAM:
+			stats.TCP[conn.Status]++
+			if conn.Status != "LISTEN" {
+				continue
+			}
+		case syscall.SOCK_DGRAM:
+			stats.UDP++
+			// A udp socket without a peer is waiting for datagrams
+			if conn.Raddr.Port != 0 || conn.Laddr.Port == 0 {
+				continue
+			}
+		default:
+			continue
+		}
+
+		// Several workers can listen on the same port with SO_REUSEPORT
+		port := ListeningPort{
+			Protocol: protocol,
+			Address:  conn.Laddr.IP,
+			Port:     conn.Laddr.Port,
+			PID:      conn.Pid,
+		}
+		if seen[port] {
+			continue
+		}
+		seen[port] = true
+
+		if port.PID > 0 {
+			port.Process = processName(port.PID)
+		}
+		stats.Listening = append(stats.Listening, port)
+	}
+
+	sort.Slice(stats.Listening, func(i, j int) bool {
+		a, b := stats.Listening[i], stats.Listening[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.PID < b.PID
+	})
+
+	return stats
+}
+
+func connectionProtocol(conn pNet.ConnectionStat) string {
+	protocol := "tcp"
+	if conn.Type == syscall.SOCK_DGRAM {
+		protocol = "udp"
+	}
+	if conn.Family == syscall.AF_INET6 {
+		protocol += "6"
+	}
+	return protocol
+}
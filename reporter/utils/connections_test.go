@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	pNet "github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestSummarizeConnections(t *testing.T) {
+	conns := []pNet.ConnectionStat{
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Status: "LISTEN", Laddr: pNet.Addr{IP: "0.0.0.0", Port: 80}, Pid: 100},
+		// Second nginx worker on the same port
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Status: "LISTEN", Laddr: pNet.Addr{IP: "0.0.0.0", Port: 80}, Pid: 100},
+		{Family: syscall.AF_INET6, Type: syscall.SOCK_STREAM, Status: "LISTEN", Laddr: pNet.Addr{IP: "::", Port: 22}},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Status: "ESTABLISHED", Laddr: pNet.Addr{IP: "10.0.0.1", Port: 80}, Raddr: pNet.Addr{IP: "10.0.0.2", Port: 51000}},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Status: "TIME_WAIT", Laddr: pNet.Addr{IP: "10.0.0.1", Port: 80}, Raddr: pNet.Addr{IP: "10.0.0.3", Port: 51001}},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Status: "TIME_WAIT", Laddr: pNet.Addr{IP: "10.0.0.1", Port: 80}, Raddr: pNet.Addr{IP: "10.0.0.4", Port: 51002}},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Status: "NONE", Laddr: pNet.Addr{IP: "127.0.0.53", Port: 53}, Pid: 200},
+		{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Status: "NONE", Laddr: pNet.Addr{IP: "10.0.0.1", Port: 40000}, Raddr: pNet.Addr{IP: "10.0.0.9", Port: 123}},
+	}
+
+	lookups := 0
+	stats := summarizeConnections(conns, func(pid int32) string {
+		lookups++
+		return map[int32]string{100: "nginx", 200: "systemd-resolve"}[pid]
+	})
+
+	assert.Equal(t, map[string]int{"LISTEN": 3, "ESTABLISHED": 1, "TIME_WAIT": 2}, stats.TCP)
+	assert.Equal(t, 2, stats.UDP)
+	assert.Equal(t, []ListeningPort{
+		{Protocol: "tcp6", Address: "::", Port: 22},
+		{Protocol: "udp", Address: "127.0.0.53", Port: 53, PID: 200, Process: "systemd-resolve"},
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 80, PID: 100, Process: "nginx"},
+	}, stats.Listening)
+	assert.Equal(t, 2, lookups)
+}
+
+func TestCollectConnections(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("can not listen on localhost")
+	}
+	defer listener.Close()
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+
+	merge, err := collectConnections(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.NotNil(t, payload.Connections)
+	assert.GreaterOrEqual(t, payload.Connections.TCP["LISTEN"], 1)
+
+	found := false
+	for _, v := range payload.Connections.Listening {
+		if v.Port == port && v.Protocol == "tcp" {
+			found = true
+		}
+	}
+	assert.True(t, found, "Should find our own listener")
+}
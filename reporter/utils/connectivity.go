@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+/**
+ * ConnectivityConfig lists the "host:port" targets which are dialed over
+ * tcp4 and tcp6. The collector runs in the background on its own interval,
+ * and does nothing until targets are configured.
+ */
+type ConnectivityConfig struct {
+	CollectorOptions
+	IPv4 []string `json:"ipv4"`
+	IPv6 []string `json:"ipv6"`
+}
+
+/**
+ * Connectivity tells whether the host can reach the internet over IPv4 and
+ * IPv6, a family is reachable when any of its targets is
+ */
+type Connectivity struct {
+	IPv4    bool                 `json:"ipv4"`
+	IPv6    bool                 `json:"ipv6"`
+	Targets []ConnectivityTarget `json:"targets"`
+}
+
+type ConnectivityTarget struct {
+	Address   string  `json:"address"`
+	Network   string  `json:"network"` // tcp4 or tcp6
+	Reachable bool    `json:"reachable"`
+	Latency   float64 `json:"latency"` // ms of tcp connect
+	Error     string  `json:"error,omitempty"`
+}
+
+type connectivityCollector struct {
+	config ConnectivityConfig
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newConnectivityCollector(config ConnectivityConfig) *connectivityCollector {
+	dialer := &net.Dialer{}
+	return &connectivityCollector{
+		config: config,
+		dial:   dialer.DialContext,
+	}
+}
+
+func (c *connectivityCollector) Name() string {
+	return "connectivity"
+}
+
+func (c *connectivityCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	if len(c.config.IPv4) == 0 && len(c.config.IPv6) == 0 {
+		return nil, nil
+	}
+
+	targets := make([]ConnectivityTarget, 0, len(c.config.IPv4)+len(c.config.IPv6))
+	for _, address := range c.config.IPv4 {
+		targets = append(targets, ConnectivityTarget{Address: address, Network: "tcp4"})
+	}
+	for _, address := range c.config.IPv6 {
+		targets = append(targets, ConnectivityTarget{Address: address, Network: "tcp6"})
+	}
+
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(target *ConnectivityTarget) {
+			defer wg.Done()
+			c.check(ctx, target)
+		}(&targets[i])
+	}
+	wg.Wait()
+
+	result := &Connectivity{Targets: targets}
+	for _, target := range targets {
+		if !target.Reachable {
+			continue
+		}
+		if target.Network == "tcp4" {
+			result.IPv4 = true
+		} else {
+			result.IPv6 = true
+		}
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.Connectivity = result
+	}, nil
+}
+
+func (c *connectivityCollector) check(ctx context.Context, target *ConnectivityTarget) {
+	start := time.Now()
+	conn, err := c.dial(ctx, target.Network, target.Address)
+	if err != nil {
+		target.Error = err.Error()
+		return
+	}
+	target.Latency = round1(float64(time.Since(start).Microseconds()) / 1000)
+	target.Reachable = true
+	conn.Close()
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectivityCollector(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	// A port which refuses connections
+	closed, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	collector := newConnectivityCollector(ConnectivityConfig{
+		IPv4: []string{closedAddress, listener.Addr().String()},
+		IPv6: []string{closedAddress},
+	})
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	result := payload.Connectivity
+	assert.True(t, result.IPv4)
+	assert.False(t, result.IPv6)
+	assert.Len(t, result.Targets, 3)
+
+	assert.Equal(t, "tcp4", result.Targets[0].Network)
+	assert.False(t, result.Targets[0].Reachable)
+	assert.NotEmpty(t, result.Targets[0].Error)
+
+	assert.Equal(t, listener.Addr().String(), result.Targets[1].Address)
+	assert.True(t, result.Targets[1].Reachable)
+	assert.Empty(t, result.Targets[1].Error)
+
+	// An ipv4 address can not be dialed over tcp6
+	assert.Equal(t, "tcp6", result.Targets[2].Network)
+	assert.False(t, result.Targets[2].Reachable)
+}
+
+func TestConnectivityCollectorNoTargets(t *testing.T) {
+	collector := newConnectivityCollector(DefaultCollectorsConfig().Connectivity)
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+}
+
+func TestConnectivityCollectorTimeout(t *testing.T) {
+	collector := newConnectivityCollector(ConnectivityConfig{IPv4: []string{"192.0.2.1:53"}})
+	collector.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	merge, err := collector.Collect(ctx)
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.False(t, payload.Connectivity.IPv4)
+	assert.Contains(t, payload.Connectivity.Targets[0].Error, "deadline exceeded")
+}
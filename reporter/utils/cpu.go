@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"math"
+)
+
+type CPUConfig struct {
+	CollectorOptions
+	Detail bool `json:"detail"` // report per-core usage and cpu time breakdown
+}
+
+/**
+ * CPUDetail is the cpu usage breakdown in percent, guest time is also
+ * counted in user time
+ */
+type CPUDetail struct {
+	User    float64   `json:"user"`
+	System  float64   `json:"system"`
+	Nice    float64   `json:"nice"`
+	Iowait  float64   `json:"iowait"`
+	Irq     float64   `json:"irq"`
+	Softirq float64   `json:"softirq"`
+	Steal   float64   `json:"steal"`
+	Guest   float64   `json:"guest"`
+	Idle    float64   `json:"idle"`
+	PerCore []float64 `json:"per_core"`
+}
+
+/**
+ * cpuCollector keeps the cumulative cpu times of last tick,
+ * cpu usage is the delta between ticks
+ */
+type cpuCollector struct {
+	detail      bool
+	prev        cpu.TimesStat
+	prevPerCore []cpu.TimesStat
+}
+
+func (c *cpuCollector) Name() string {
+	return "cpu"
+}
+
+func (c *cpuCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	cpuTimes, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(cpuTimes) == 0 {
+		return nil, fmt.Errorf("unable to get cpu usage information")
+	}
+
+	// The first tick compares with zero, which is the average usage since boot
+	prev := c.prev
+	c.prev = cpuTimes[0]
+	percent := calculateCPUPercent(prev, cpuTimes[0])
+
+	var detail *CPUDetail
+	if c.detail {
+		detail = calculateCPUDetail(prev, cpuTimes[0])
+		detail.PerCore, err = c.getPerCore(ctx)
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.CPU = jsoniter.Number(fmt.Sprintf("%.1f", percent))
+		payload.CPUDetail = detail
+	}, err
+}
+
+func (c *cpuCollector) getPerCore(ctx context.Context) ([]float64, error) {
+	cpuTimes, err := cpu.TimesWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cores changed, e.g. cpu hotplug
+	if len(c.prevPerCore) != len(cpuTimes) {
+		c.prevPerCore = make([]cpu.TimesStat, len(cpuTimes))
+	}
+
+	perCore := make([]float64, len(cpuTimes))
+	for i, times := range cpuTimes {
+		perCore[i] = round1(calculateCPUPercent(c.prevPerCore[i], times))
+	}
+	c.prevPerCore = cpuTimes
+
+	return perCore, nil
+}
+
+func calculateCPUDetail(prev cpu.TimesStat, current cpu.TimesStat) *CPUDetail {
+	prevTotal, _ := getCPUTotalAndBusy(prev)
+	total, _ := getCPUTotalAndBusy(current)
+
+	delta := total - prevTotal
+	percent := func(prev float64, current float64) float64 {
+		if delta <= 0 || current <= prev {
+			return 0
+		}
+		return round1((current - prev) / delta * 100)
+	}
+
+	return &CPUDetail{
+		User:    percent(prev.User, current.User),
+		System:  percent(prev.System, current.System),
+		Nice:    percent(prev.Nice, current.Nice),
+		Iowait:  percent(prev.Iowait, current.Iowait),
+		Irq:     percent(prev.Irq, current.Irq),
+		Softirq: percent(prev.Softirq, current.Softirq),
+		Steal:   percent(prev.Steal, current.Steal),
+		Guest:   percent(prev.Guest, current.Guest),
+		Idle:    percent(prev.Idle, current.Idle),
+	}
+}
+
+// round1 rounds to one decimal place
+func round1(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+func calculateCPUPercent(prev cpu.TimesStat, current cpu.TimesStat) float64 {
+	prevTotal, prevBusy := getCPUTotalAndBusy(prev)
+	total, busy := getCPUTotalAndBusy(current)
+
+	if total <= prevTotal || busy <= prevBusy {
+		return 0
+	}
+
+	return math.Min(100, (busy-prevBusy)/(total-prevTotal)*100)
+}
+
+func getCPUTotalAndBusy(t cpu.TimesStat) (float64, float64) {
+	// Guest time is already counted in user time on linux
+	total := t.User + t.System + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal + t.Idle
+	busy := total - t.Idle - t.Iowait
+	return total, busy
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCalculateCPUPercent(t *testing.T) {
+	prev := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 50}
+	current := cpu.TimesStat{User: 130, System: 60, Idle: 850, Iowait: 60}
+
+	// 40 busy in 100 total
+	assert.InDelta(t, 40.0, calculateCPUPercent(prev, current), 0.001)
+
+	// No time passed between two samples
+	assert.Equal(t, 0.0, calculateCPUPercent(current, current))
+
+	// Counters reset, e.g. cpu hotplug
+	assert.Equal(t, 0.0, calculateCPUPercent(current, prev))
+}
+
+func TestGetCpuDoesNotBlock(t *testing.T) {
+	start := time.Now()
+	collector := &cpuCollector{}
+	_, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	_, err = collector.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCalculateCPUDetail(t *testing.T) {
+	prev := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 50, Steal: 10, Guest: 5}
+	current := cpu.TimesStat{User: 120, System: 60, Idle: 840, Iowait: 60, Steal: 30, Guest: 15}
+
+	detail := calculateCPUDetail(prev, current)
+	assert.Equal(t, 20.0, detail.User)
+	assert.Equal(t, 10.0, detail.System)
+	assert.Equal(t, 40.0, detail.Idle)
+	assert.Equal(t, 10.0, detail.Iowait)
+	assert.Equal(t, 20.0, detail.Steal)
+	assert.Equal(t, 10.0, detail.Guest)
+	assert.Equal(t, 0.0, detail.Nice)
+}
+
+func TestCPUCollectorDetail(t *testing.T) {
+	collector := &cpuCollector{detail: true}
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.NotNil(t, payload.CPUDetail)
+	assert.NotEmpty(t, payload.CPUDetail.PerCore)
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/disk"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Partitions rarely change, so they are only scanned every 5 minutes
+const diskScanInterval = 5 * time.Minute
+
+/**
+ * DiskConfig selects the reported filesystems. Without rules a filesystem
+ * is reported when its type is in validFs, an included mountpoint is always
+ * reported and an include list of fs types replaces validFs.
+ */
+type DiskConfig struct {
+	CollectorOptions
+	Mountpoints Filter `json:"mountpoints"`
+	FsTypes     Filter `json:"fs_types"`
+}
+
+// DiskUsage is the usage of one mountpoint, sizes are in bytes
+type DiskUsage struct {
+	Device            string  `json:"device"`
+	Mountpoint        string  `json:"mountpoint"`
+	Fstype            string  `json:"fstype"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	UsedPercent       float64 `json:"used_percent"`
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+}
+
+type diskCollector struct {
+	config     DiskConfig
+	partitions map[string]disk.PartitionStat
+	lastScan   time.Time
+}
+
+func (c *diskCollector) Name() string {
+	return "disk"
+}
+
+func (c *diskCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	disks, err := c.getDisks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var hddTotal, hddUsed uint64
+	for _, d := range disks {
+		hddTotal += d.Total / 1024.0 / 1024.0
+		hddUsed += d.Used / 1024.0 / 1024.0
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.HddTotal = hddTotal
+		payload.HddUsed = hddUsed
+		payload.Disks = disks
+	}, nil
+}
+
+func (c *diskCollector) getDisks(ctx context.Context) ([]DiskUsage, error) {
+	if c.partitions == nil || time.Since(c.lastScan) >= diskScanInterval {
+		diskList, err := disk.PartitionsWithContext(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		c.partitions = make(map[string]disk.PartitionStat)
+		devices := make(map[string]struct{})
+		for _, d := range diskList {
+			_, ok := devices[d.Device]
+			if !ok && c.checkPartition(d) {
+				c.partitions[d.Mountpoint] = d
+				devices[d.Device] = struct{}{}
+			}
+		}
+		c.lastScan = time.Now()
+	}
+
+	disks := make([]DiskUsage, 0, len(c.partitions))
+	for k, d := range c.partitions {
+		usage, err := disk.UsageWithContext(ctx, k)
+		if err != nil {
+			delete(c.partitions, k)
+			continue
+		}
+		disks = append(disks, DiskUsage{
+			Device:            d.Device,
+			Mountpoint:        d.Mountpoint,
+			Fstype:            d.Fstype,
+			Total:             usage.Total,
+			Used:              usage.Used,
+			Free:              usage.Free,
+			UsedPercent:       round1(usage.UsedPercent),
+			InodesTotal:       usage.InodesTotal,
+			InodesUsed:        usage.InodesUsed,
+			InodesFree:        usage.InodesFree,
+			InodesUsedPercent: round1(usage.InodesUsedPercent),
+		})
+	}
+
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Mountpoint < disks[j].Mountpoint
+	})
+
+	return disks, nil
+}
+
+func (c *diskCollector) checkPartition(d disk.PartitionStat) bool {
+	if c.config.Mountpoints.Excluded(d.Mountpoint) || c.config.FsTypes.Excluded(d.Fstype) {
+		return false
+	}
+	if c.config.Mountpoints.Included(d.Mountpoint) {
+		return true
+	}
+	if len(c.config.Mountpoints.Include) > 0 {
+		return false
+	}
+	return c.config.FsTypes.Match(strings.ToLower(d.Fstype), checkValidFs)
+}
+
+var validFs = []string{"ext4", "ext3", "ext2", "reiserfs", "jfs", "btrfs", "fuseblk", "zfs", "simfs", "ntfs", "fat32", "exfat", "xfs", "apfs"}
+
+func checkValidFs(name string) bool {
+	for _, v := range validFs {
+		if strings.ToLower(name) == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiskCollectorCheckPartition(t *testing.T) {
+	root := disk.PartitionStat{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"}
+	data := disk.PartitionStat{Device: "/dev/sdb1", Mountpoint: "/data", Fstype: "xfs"}
+	tmp := disk.PartitionStat{Device: "tmpfs", Mountpoint: "/tmp", Fstype: "tmpfs"}
+	nfs := disk.PartitionStat{Device: "nas:/export", Mountpoint: "/mnt/nas", Fstype: "nfs4"}
+
+	// Default rules come from validFs
+	collector := &diskCollector{}
+	assert.True(t, collector.checkPartition(root))
+	assert.True(t, collector.checkPartition(data))
+	assert.False(t, collector.checkPartition(tmp))
+	assert.False(t, collector.checkPartition(nfs))
+
+	// Included mountpoint overrides validFs, excluded one is dropped
+	collector = &diskCollector{config: DiskConfig{
+		Mountpoints: Filter{Include: []string{"/", "/mnt/*"}},
+	}}
+	assert.True(t, collector.checkPartition(root))
+	assert.True(t, collector.checkPartition(nfs))
+	assert.False(t, collector.checkPartition(data))
+
+	// Fs type include list replaces validFs
+	collector = &diskCollector{config: DiskConfig{
+		FsTypes:     Filter{Include: []string{"ext4", "nfs*"}},
+		Mountpoints: Filter{Exclude: []string{"/"}},
+	}}
+	assert.False(t, collector.checkPartition(root))
+	assert.False(t, collector.checkPartition(data))
+	assert.True(t, collector.checkPartition(nfs))
+}
+
+func TestDiskCollector(t *testing.T) {
+	merge, err := (&diskCollector{}).Collect(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+
+	var total uint64
+	for _, d := range payload.Disks {
+		assert.NotEmpty(t, d.Mountpoint)
+		assert.GreaterOrEqual(t, d.Total, d.Used)
+		total += d.Total / 1024 / 1024
+	}
+	assert.Equal(t, total, payload.HddTotal)
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/disk"
+	"sort"
+	"strings"
+	"time"
+)
+
+type DiskIOConfig struct {
+	CollectorOptions
+	Devices Filter `json:"devices"`
+}
+
+/**
+ * DiskIO is the io of one block device between two reports, like iostat -x
+ */
+type DiskIO struct {
+	Name        string  `json:"name"`
+	ReadBytes   uint64  `json:"read_bytes"`  // bytes per second
+	WriteBytes  uint64  `json:"write_bytes"` // bytes per second
+	ReadIOPS    float64 `json:"read_iops"`
+	WriteIOPS   float64 `json:"write_iops"`
+	ReadAwait   float64 `json:"read_await"`   // ms
+	WriteAwait  float64 `json:"write_await"`  // ms
+	Await       float64 `json:"await"`        // ms
+	ServiceTime float64 `json:"service_time"` // ms
+	Util        float64 `json:"util"`         // percent of time the device was busy
+}
+
+type diskIOCollector struct {
+	config   DiskIOConfig
+	prev     map[string]disk.IOCountersStat
+	prevTime time.Time
+}
+
+func (c *diskIOCollector) Name() string {
+	return "diskio"
+}
+
+func (c *diskIOCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(c.prevTime)
+	prev := c.prev
+	c.prev = counters
+	c.prevTime = now
+
+	// Rates need two samples
+	if prev == nil || elapsed <= 0 {
+		return nil, nil
+	}
+
+	result := make([]DiskIO, 0, len(counters))
+	for name, current := range counters {
+		if !c.config.Devices.Match(name, checkIODevice) {
+			continue
+		}
+		last, ok := prev[name]
+		if !ok {
+			continue
+		}
+		if io, ok := calculateDiskIO(last, current, elapsed); ok {
+			result = append(result, io)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return func(payload *ReportDataPayload) {
+		payload.DiskIO = result
+	}, nil
+}
+
+// calculateDiskIO returns false when the counters were reset
+func calculateDiskIO(prev disk.IOCountersStat, current disk.IOCountersStat, elapsed time.Duration) (DiskIO, bool) {
+	if current.ReadCount < prev.ReadCount || current.WriteCount < prev.WriteCount ||
+		current.ReadBytes < prev.ReadBytes || current.WriteBytes < prev.WriteBytes ||
+		current.ReadTime < prev.ReadTime || current.WriteTime < prev.WriteTime ||
+		current.IoTime < prev.IoTime {
+		return DiskIO{}, false
+	}
+
+	seconds := elapsed.Seconds()
+	reads := float64(current.ReadCount - prev.ReadCount)
+	writes := float64(current.WriteCount - prev.WriteCount)
+	readTime := float64(current.ReadTime - prev.ReadTime)
+	writeTime := float64(current.WriteTime - prev.WriteTime)
+	ioTime := float64(current.IoTime - prev.IoTime)
+
+	io := DiskIO{
+		Name:       current.Name,
+		ReadBytes:  uint64(float64(current.ReadBytes-prev.ReadBytes) / seconds),
+		WriteBytes: uint64(float64(current.WriteBytes-prev.WriteBytes) / seconds),
+		ReadIOPS:   round1(reads / seconds),
+		WriteIOPS:  round1(writes / seconds),
+		Util:       round1(min(100, ioTime/float64(elapsed.Milliseconds())*100)),
+	}
+	if reads > 0 {
+		io.ReadAwait = round1(readTime / reads)
+	}
+	if writes > 0 {
+		io.WriteAwait = round1(writeTime / writes)
+	}
+	if reads+writes > 0 {
+		io.Await = round1((readTime + writeTime) / (reads + writes))
+		io.ServiceTime = round1(ioTime / (reads + writes))
+	}
+
+	return io, true
+}
+
+var invalidIODevice = []string{"loop", "ram", "zram", "fd", "sr"}
+
+func checkIODevice(name string) bool {
+	for _, v := range invalidIODevice {
+		if strings.HasPrefix(name, v) {
+			return false
+		}
+	}
+	return true
+}
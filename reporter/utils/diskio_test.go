@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCalculateDiskIO(t *testing.T) {
+	prev := disk.IOCountersStat{Name: "sda", ReadCount: 100, WriteCount: 200, ReadBytes: 1000, WriteBytes: 2000, ReadTime: 50, WriteTime: 100, IoTime: 1000}
+	current := disk.IOCountersStat{Name: "sda", ReadCount: 120, WriteCount: 260, ReadBytes: 21000, WriteBytes: 62000, ReadTime: 90, WriteTime: 340, IoTime: 1500}
+
+	io, ok := calculateDiskIO(prev, current, 2*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, "sda", io.Name)
+	assert.Equal(t, uint64(10000), io.ReadBytes)
+	assert.Equal(t, uint64(30000), io.WriteBytes)
+	assert.Equal(t, 10.0, io.ReadIOPS)
+	assert.Equal(t, 30.0, io.WriteIOPS)
+	assert.Equal(t, 2.0, io.ReadAwait)
+	assert.Equal(t, 4.0, io.WriteAwait)
+	assert.Equal(t, 3.5, io.Await)
+	assert.Equal(t, 6.3, io.ServiceTime)
+	assert.Equal(t, 25.0, io.Util)
+
+	// Counters were reset, e.g. device re-attached
+	_, ok = calculateDiskIO(current, prev, 2*time.Second)
+	assert.False(t, ok)
+}
+
+func TestDiskIOCollectorFirstSample(t *testing.T) {
+	collector := &diskIOCollector{}
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+
+	merge, err = collector.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, merge)
+}
+
+func TestCheckIODevice(t *testing.T) {
+	assert.True(t, checkIODevice("sda"))
+	assert.True(t, checkIODevice("nvme0n1"))
+	assert.False(t, checkIODevice("loop0"))
+	assert.False(t, checkIODevice("zram0"))
+}
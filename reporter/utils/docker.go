@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type DockerDataPayload struct {
+	ID               string       `json:"id"`
+	Image            string       `json:"image"`
+	ImageID          string       `json:"imageId"`
+	Ports            []DockerPort `json:"ports"`
+	CreatedAt        int64        `json:"createdAt"`
+	State            string       `json:"state"`
+	Status           string       `json:"status"`
+	CpuPercent       float64      `json:"cpuPercent"`
+	Memory           float64      `json:"memory"`
+	MemLimit         uint64       `json:"memLimit"`
+	MemPercent       float64      `json:"memPercent"`
+	StorageWriteSize uint64       `json:"storageWriteSize"`
+	StorageReadSize  uint64       `json:"storageReadSize"`
+	NetworkRx        float64      `json:"networkRx"`
+	NetworkTx        float64      `json:"networkTx"`
+	IORead           uint64       `json:"ioRead"`
+	IOWrite          uint64       `json:"ioWrite"`
+}
+
+type DockerPort struct {
+	IP          string `json:"IP,omitempty"`
+	PrivatePort uint16 `json:"PrivatePort"`
+	PublicPort  uint16 `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+type dockerContainerSummary struct {
+	ID      string       `json:"Id"`
+	Image   string       `json:"Image"`
+	ImageID string       `json:"ImageID"`
+	Ports   []DockerPort `json:"Ports"`
+	Created int64        `json:"Created"`
+	State   string       `json:"State"`
+	Status  string       `json:"Status"`
+}
+
+type dockerStatsResponse struct {
+	Read         time.Time                     `json:"read"`
+	PreRead      time.Time                     `json:"preread"`
+	NumProcs     uint32                        `json:"num_procs"`
+	CPUStats     dockerCPUStats                `json:"cpu_stats"`
+	PreCPUStats  dockerCPUStats                `json:"precpu_stats"`
+	MemoryStats  dockerMemoryStats             `json:"memory_stats"`
+	BlkioStats   dockerBlkioStats              `json:"blkio_stats"`
+	StorageStats dockerStorageStats            `json:"storage_stats"`
+	Networks     map[string]dockerNetworkStats `json:"networks"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage    dockerCPUUsage `json:"cpu_usage"`
+	SystemUsage uint64         `json:"system_cpu_usage"`
+}
+
+type dockerCPUUsage struct {
+	TotalUsage  uint64   `json:"total_usage"`
+	PercpuUsage []uint64 `json:"percpu_usage"`
+}
+
+type dockerMemoryStats struct {
+	Usage             uint64 `json:"usage"`
+	Limit             uint64 `json:"limit"`
+	PrivateWorkingSet uint64 `json:"privateworkingset"`
+}
+
+type dockerBlkioStats struct {
+	IoServiceBytesRecursive []dockerBlkioEntry `json:"io_service_bytes_recursive"`
+}
+
+type dockerBlkioEntry struct {
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+type dockerStorageStats struct {
+	ReadSizeBytes  uint64 `json:"read_size_bytes"`
+	WriteSizeBytes uint64 `json:"write_size_bytes"`
+}
+
+type dockerNetworkStats struct {
+	RxBytes uint64 `json:"rx_bytes"`
+	TxBytes uint64 `json:"tx_bytes"`
+}
+
+func collectDocker(ctx context.Context) (MergeFunc, error) {
+	dockerStat, err := GetDockerStat(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		// Docker is not installed on this machine
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.Docker = dockerStat
+	}, nil
+}
+
+func GetDockerStat(ctx context.Context) ([]DockerDataPayload, error) {
+	httpClient, baseURL, err := newDockerHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []dockerContainerSummary
+	_, err = getDockerJSON(ctx, httpClient, baseURL, "/containers/json?all=1", &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	var dockerPayloads []DockerDataPayload
+
+	for _, container := range containers {
+		var v dockerStatsResponse
+		resp, err := getDockerJSON(
+			ctx,
+			httpClient,
+			baseURL,
+			"/containers/"+url.PathEscape(container.ID)+"/stats?stream=false",
+			&v,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var cpuPercent float64
+		var blkRead, blkWrite uint64
+		var mem float64
+		var memPercent float64
+		if resp.Header.Get("OSType") != "windows" {
+			if v.MemoryStats.Limit != 0 {
+				memPercent = float64(v.MemoryStats.Usage) / float64(v.MemoryStats.Limit) * 100.0
+			}
+			cpuPercent = calculateCPUPercentUnix(&v)
+			blkRead, blkWrite = calculateBlockIO(v.BlkioStats)
+			mem = float64(v.MemoryStats.Usage)
+		} else {
+			cpuPercent = calculateCPUPercentWindows(&v)
+			blkRead = v.StorageStats.ReadSizeBytes
+			blkWrite = v.StorageStats.WriteSizeBytes
+			mem = float64(v.MemoryStats.PrivateWorkingSet)
+		}
+
+		netRx, netTx := calculateNetwork(v.Networks)
+
+		dockerPayloads = append(dockerPayloads, DockerDataPayload{
+			ID:               container.ID[:10],
+			Image:            container.Image,
+			ImageID:          container.ImageID,
+			Ports:            container.Ports,
+			CreatedAt:        container.Created,
+			State:            container.State,
+			Status:           container.Status,
+			CpuPercent:       cpuPercent,
+			Memory:           mem,
+			MemLimit:         v.MemoryStats.Limit,
+			MemPercent:       memPercent,
+			StorageWriteSize: v.StorageStats.WriteSizeBytes,
+			StorageReadSize:  v.StorageStats.ReadSizeBytes,
+			NetworkRx:        netRx,
+			NetworkTx:        netTx,
+			IORead:           blkRead,
+			IOWrite:          blkWrite,
+		})
+
+	}
+
+	return dockerPayloads, nil
+}
+
+func newDockerHTTPClient() (*http.Client, string, error) {
+	dockerHost := os.Getenv("DOCKER_HOST")
+	if dockerHost == "" {
+		dockerHost = "unix:///var/run/docker.sock"
+	}
+
+	hostURL, err := url.Parse(dockerHost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch hostURL.Scheme {
+	case "unix":
+		socketPath := hostURL.Path
+		if socketPath == "" {
+			socketPath = "/var/run/docker.sock"
+		}
+
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}
+
+		return &http.Client{Transport: transport}, "http://docker", nil
+	case "tcp":
+		hostURL.Scheme = "http"
+		return http.DefaultClient, strings.TrimRight(hostURL.String(), "/"), nil
+	case "http", "https":
+		return http.DefaultClient, strings.TrimRight(hostURL.String(), "/"), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported Docker host: %s", dockerHost)
+	}
+}
+
+func getDockerJSON(ctx context.Context, client *http.Client, baseURL string, endpoint string, out interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp, fmt.Errorf("Docker API request failed: %s %s", endpoint, resp.Status)
+	}
+
+	if err := jsoniter.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+/**
+ * Reference: https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L175
+ */
+func calculateCPUPercentUnix(v *dockerStatsResponse) float64 {
+	previousCPU := v.PreCPUStats.CPUUsage.TotalUsage
+	previousSystem := v.PreCPUStats.SystemUsage
+	cpuPercent := 0.0
+	// calculate the change for the cpu usage of the container in between readings
+	cpuDelta := float64(v.CPUStats.CPUUsage.TotalUsage) - float64(previousCPU)
+	// calculate the change for the entire system between readings
+	systemDelta := float64(v.CPUStats.SystemUsage) - float64(previousSystem)
+
+	if systemDelta > 0.0 && cpuDelta > 0.0 {
+		cpuPercent = (cpuDelta / systemDelta) * float64(len(v.CPUStats.CPUUsage.PercpuUsage)) * 100.0
+	}
+	return cpuPercent
+}
+
+/**
+ * Reference: https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L190
+ */
+func calculateCPUPercentWindows(v *dockerStatsResponse) float64 {
+	// Max number of 100ns intervals between the previous time read and now
+	possIntervals := uint64(v.Read.Sub(v.PreRead).Nanoseconds()) // Start with number of ns intervals
+	possIntervals /= 100                                         // Convert to number of 100ns intervals
+	possIntervals *= uint64(v.NumProcs)                          // Multiple by the number of processors
+
+	// Intervals used
+	intervalsUsed := v.CPUStats.CPUUsage.TotalUsage - v.PreCPUStats.CPUUsage.TotalUsage
+
+	// Percentage avoiding divide-by-zero
+	if possIntervals > 0 {
+		return float64(intervalsUsed) / float64(possIntervals) * 100.0
+	}
+	return 0.00
+}
+
+/**
+ * Reference: https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L206
+ */
+func calculateBlockIO(blkio dockerBlkioStats) (blkRead uint64, blkWrite uint64) {
+	for _, bioEntry := range blkio.IoServiceBytesRecursive {
+		switch strings.ToLower(bioEntry.Op) {
+		case "read":
+			blkRead = blkRead + bioEntry.Value
+		case "write":
+			blkWrite = blkWrite + bioEntry.Value
+		}
+	}
+	return
+}
+
+/**
+ * Reference: https://github.com/moby/moby/blob/eb131c5383db8cac633919f82abad86c99bffbe5/cli/command/container/stats_helpers.go#L218
+ */
+func calculateNetwork(network map[string]dockerNetworkStats) (float64, float64) {
+	var rx, tx float64
+
+	for _, v := range network {
+		rx += float64(v.RxBytes)
+		tx += float64(v.TxBytes)
+	}
+	return rx, tx
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"time"
+)
+
+/**
+ * Duration is a time.Duration which is written as "30s" or "5m" in config
+ * files, a plain number is treated as seconds
+ */
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := jsoniter.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case float64:
+		*d = Duration(v * float64(time.Second))
+		return nil
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+}
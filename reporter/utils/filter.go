@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"path"
+)
+
+/**
+ * Filter selects names by exact name or glob pattern, e.g. "eth0" or "veth*".
+ * Exclude always wins, a non-empty Include only keeps the matched names.
+ */
+type Filter struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+func (f Filter) Included(name string) bool {
+	return matchAny(f.Include, name)
+}
+
+func (f Filter) Excluded(name string) bool {
+	return matchAny(f.Exclude, name)
+}
+
+// Match reports whether name passes the filter, fallback decides names
+// which are not covered by any rule
+func (f Filter) Match(name string, fallback func(name string) bool) bool {
+	if f.Excluded(name) {
+		return false
+	}
+	if len(f.Include) > 0 {
+		return f.Included(name)
+	}
+	if fallback != nil {
+		return fallback(name)
+	}
+	return true
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
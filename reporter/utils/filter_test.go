@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	filter := Filter{Exclude: []string{"lo", "veth*"}}
+	assert.False(t, filter.Match("lo", nil))
+	assert.False(t, filter.Match("veth1a2b", nil))
+	assert.True(t, filter.Match("wlo1", nil))
+	assert.True(t, filter.Match("eth0", nil))
+
+	filter = Filter{Include: []string{"eth*", "/data"}, Exclude: []string{"eth1"}}
+	assert.True(t, filter.Match("eth0", nil))
+	assert.False(t, filter.Match("eth1", nil))
+	assert.False(t, filter.Match("wlan0", nil))
+	assert.True(t, filter.Match("/data", nil))
+
+	// Names without rules are decided by the fallback
+	filter = Filter{}
+	assert.False(t, filter.Match("tmpfs", checkValidFs))
+	assert.True(t, filter.Match("ext4", checkValidFs))
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/host"
+	"time"
+)
+
+func collectHost(ctx context.Context) (MergeFunc, error) {
+	uptime, err := getUptime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.Uptime = uptime
+	}, nil
+}
+
+func getUptime(ctx context.Context) (uint64, error) {
+	bootTime, err := host.BootTimeWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(time.Now().Unix()) - bootTime, nil
+}
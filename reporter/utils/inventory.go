@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+/**
+ * HostInfo describes what the machine is. It rarely changes, so it is only
+ * sent with the first report after each inventory interval, the server
+ * keeps the last one.
+ */
+type HostInfo struct {
+	OS                   string `json:"os"`
+	Platform             string `json:"platform"`
+	PlatformFamily       string `json:"platform_family"`
+	PlatformVersion      string `json:"platform_version"`
+	KernelVersion        string `json:"kernel_version"`
+	KernelArch           string `json:"kernel_arch"`
+	CPUModel             string `json:"cpu_model"`
+	CPUCores             int    `json:"cpu_cores"`   // physical cores
+	CPUThreads           int    `json:"cpu_threads"` // logical cores
+	VirtualizationSystem string `json:"virtualization_system"`
+	VirtualizationRole   string `json:"virtualization_role"`
+	BootTime             uint64 `json:"boot_time"` // unix seconds
+}
+
+type inventoryCollector struct {
+	info func(ctx context.Context) (HostInfo, error)
+}
+
+func newInventoryCollector() *inventoryCollector {
+	return &inventoryCollector{info: getHostInfo}
+}
+
+func (c *inventoryCollector) Name() string {
+	return "inventory"
+}
+
+func (c *inventoryCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	info, err := c.info(ctx)
+	if info == (HostInfo{}) {
+		return nil, err
+
+	}
+	// The registry reuses the merge until the next collection, only the
+	// first report after it carries the info. It is sent again even if
+	// nothing changed, so the server gets it back after losing it
+	merged := false
+	return func(payload *ReportDataPayload) {
+		if merged {
+			return
+		}
+		merged = true
+		payload.HostInfo = &info
+	}, err
+}
+
+func getHostInfo(ctx context.Context) (HostInfo, error) {
+	hostInfo, err := host.InfoWithContext(ctx)
+	if err != nil {
+		return HostInfo{}, err
+	}
+
+	info := HostInfo{
+		OS:                   hostInfo.OS,
+		Platform:             hostInfo.Platform,
+		PlatformFamily:       hostInfo.PlatformFamily,
+		PlatformVersion:      hostInfo.PlatformVersion,
+		KernelVersion:        hostInfo.KernelVersion,
+		KernelArch:           hostInfo.KernelArch,
+		VirtualizationSystem: hostInfo.VirtualizationSystem,
+		VirtualizationRole:   hostInfo.VirtualizationRole,
+		BootTime:             hostInfo.BootTime,
+	}
+
+	// Keep the host part if the cpu can not be read, e.g. in some containers
+	cpuInfo, err := cpu.InfoWithContext(ctx)
+	if err == nil && len(cpuInfo) > 0 {
+		info.CPUModel = cpuInfo[0].ModelName
+	}
+	if cores, coresErr := cpu.CountsWithContext(ctx, false); coresErr == nil {
+		info.CPUCores = cores
+	} else if err == nil {
+		err = coresErr
+	}
+	if threads, threadsErr := cpu.CountsWithContext(ctx, true); threadsErr == nil {
+		info.CPUThreads = threads
+	} else if err == nil {
+		err = threadsErr
+	}
+
+	return info, err
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInventoryCollectorResend(t *testing.T) {
+	info := HostInfo{OS: "linux", Platform: "ubuntu", PlatformVersion: "22.04", CPUCores: 4, CPUThreads: 8}
+	var infoErr error
+	collector := &inventoryCollector{
+		info: func(ctx context.Context) (HostInfo, error) {
+			return info, infoErr
+		},
+	}
+	collect := func() (*HostInfo, *HostInfo) {
+		merge, err := collector.Collect(context.Background())
+		assert.Equal(t, infoErr, err)
+		if merge == nil {
+			return nil, nil
+		}
+		first, second := ReportDataPayload{}, ReportDataPayload{}
+		merge(&first)
+		merge(&second)
+		return first.HostInfo, second.HostInfo
+	}
+
+	// Sent once on startup, a reused merge does not send it again
+	first, second := collect()
+	assert.Equal(t, &info, first)
+	assert.Nil(t, second)
+
+	// Sent again on the next collection even if nothing changed
+	first, second = collect()
+	assert.Equal(t, &info, first)
+	assert.Nil(t, second)
+
+	info.PlatformVersion = "24.04"
+	first, _ = collect()
+	assert.Equal(t, "24.04", first.PlatformVersion)
+
+	// Nothing to send when the host can not be read
+	info = HostInfo{}
+	infoErr = errors.New("permission denied")
+	first, _ = collect()
+	assert.Nil(t, first)
+}
+
+func TestGetHostInfo(t *testing.T) {
+	info, err := getHostInfo(context.Background())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, info.OS)
+	assert.NotEmpty(t, info.KernelArch)
+	assert.Greater(t, info.CPUThreads, 0)
+	assert.Greater(t, info.BootTime, uint64(0))
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/shirou/gopsutil/v4/load"
+)
+
+type LoadDetail struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	ProcsRunning int     `json:"procs_running"`
+	ProcsBlocked int     `json:"procs_blocked"`
+	ProcsTotal   int     `json:"procs_total"`
+}
+
+func collectLoad(ctx context.Context) (MergeFunc, error) {
+	theLoad, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &LoadDetail{
+		Load1:  theLoad.Load1,
+		Load5:  theLoad.Load5,
+		Load15: theLoad.Load15,
+	}
+
+	// Task counts are not available on every platform, keep load averages anyway
+	misc, err := load.MiscWithContext(ctx)
+	if err == nil {
+		detail.ProcsRunning = misc.ProcsRunning
+		detail.ProcsBlocked = misc.ProcsBlocked
+		detail.ProcsTotal = misc.ProcsTotal
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.Load = jsoniter.Number(fmt.Sprintf("%.2f", theLoad.Load1))
+		payload.LoadDetail = detail
+	}, err
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/mem"
+	"runtime"
+	"time"
+)
+
+/**
+ * MemoryDetail is the memory breakdown in KB, swap in/out are KB per second.
+ * Some fields are only available on linux.
+ */
+type MemoryDetail struct {
+	Available      uint64 `json:"available"`
+	Free           uint64 `json:"free"`
+	Cached         uint64 `json:"cached"`
+	Buffers        uint64 `json:"buffers"`
+	Shared         uint64 `json:"shared"`
+	Slab           uint64 `json:"slab"`
+	SReclaimable   uint64 `json:"sreclaimable"`
+	Dirty          uint64 `json:"dirty"`
+	WriteBack      uint64 `json:"writeback"`
+	HugePagesTotal uint64 `json:"hugepages_total"`
+	HugePagesFree  uint64 `json:"hugepages_free"`
+	HugePageSize   uint64 `json:"hugepage_size"`
+	SwapIn         uint64 `json:"swap_in"`
+	SwapOut        uint64 `json:"swap_out"`
+}
+
+type memoryCollector struct {
+	prevSwapIn  uint64
+	prevSwapOut uint64
+	prevTime    time.Time
+}
+
+func (c *memoryCollector) Name() string {
+	return "memory"
+}
+
+func (c *memoryCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	memory, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Swap is optional, e.g. containers may not expose it
+	swap, swapErr := mem.SwapMemoryWithContext(ctx)
+
+	memoryTotal, memoryUsed, swapTotal, swapUsed := getMemory(memory, swap)
+	detail := &MemoryDetail{
+		Available:      memory.Available / 1024,
+		Free:           memory.Free / 1024,
+		Cached:         memory.Cached / 1024,
+		Buffers:        memory.Buffers / 1024,
+		Shared:         memory.Shared / 1024,
+		Slab:           memory.Slab / 1024,
+		SReclaimable:   memory.Sreclaimable / 1024,
+		Dirty:          memory.Dirty / 1024,
+		WriteBack:      memory.WriteBack / 1024,
+		HugePagesTotal: memory.HugePagesTotal,
+		HugePagesFree:  memory.HugePagesFree,
+		HugePageSize:   memory.HugePageSize / 1024,
+	}
+	if swap != nil {
+		detail.SwapIn, detail.SwapOut = c.getSwapRate(swap)
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.MemoryTotal = memoryTotal
+		payload.MemoryUsed = memoryUsed
+		payload.SwapTotal = swapTotal
+		payload.SwapUsed = swapUsed
+		payload.MemoryDetail = detail
+	}, swapErr
+}
+
+// getSwapRate returns swap in/out in KB per second, the first sample has no rate
+func (c *memoryCollector) getSwapRate(swap *mem.SwapMemoryStat) (uint64, uint64) {
+	now := time.Now()
+	prevTime := c.prevTime
+	prevIn, prevOut := c.prevSwapIn, c.prevSwapOut
+	c.prevSwapIn, c.prevSwapOut, c.prevTime = swap.Sin, swap.Sout, now
+
+	elapsed := now.Sub(prevTime).Seconds()
+	if prevTime.IsZero() || elapsed <= 0 || swap.Sin < prevIn || swap.Sout < prevOut {
+		return 0, 0
+	}
+
+	return uint64(float64(swap.Sin-prevIn) / 1024 / elapsed), uint64(float64(swap.Sout-prevOut) / 1024 / elapsed)
+}
+
+/**
+ * Fork from https://github.com/cokemine/ServerStatus-goclient/blob/master/pkg/status/status.go
+ */
+func getMemory(memory *mem.VirtualMemoryStat, swap *mem.SwapMemoryStat) (uint64, uint64, uint64, uint64) {
+	if runtime.GOOS == "linux" {
+		return memory.Total / 1024.0, memory.Used / 1024.0, memory.SwapTotal / 1024.0, (memory.SwapTotal - memory.SwapFree) / 1024.0
+	} else if swap == nil {
+		return memory.Total / 1024.0, memory.Used / 1024.0, 0, 0
+	} else {
+		return memory.Total / 1024.0, memory.Used / 1024.0, swap.Total / 1024.0, swap.Used / 1024.0
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryCollectorSwapRate(t *testing.T) {
+	collector := &memoryCollector{}
+
+	// The first sample only records the counters
+	swapIn, swapOut := collector.getSwapRate(&mem.SwapMemoryStat{Sin: 1024 * 1024, Sout: 2048 * 1024})
+	assert.Equal(t, uint64(0), swapIn)
+	assert.Equal(t, uint64(0), swapOut)
+
+	collector.prevTime = time.Now().Add(-2 * time.Second)
+	swapIn, swapOut = collector.getSwapRate(&mem.SwapMemoryStat{Sin: 1024*1024 + 40*1024, Sout: 2048 * 1024})
+	assert.InDelta(t, 20, swapIn, 1)
+	assert.Equal(t, uint64(0), swapOut)
+
+	// Counters went backwards
+	collector.prevTime = time.Now().Add(-2 * time.Second)
+	swapIn, swapOut = collector.getSwapRate(&mem.SwapMemoryStat{Sin: 0, Sout: 0})
+	assert.Equal(t, uint64(0), swapIn)
+	assert.Equal(t, uint64(0), swapOut)
+}
+
+func TestMemoryCollector(t *testing.T) {
+	merge, err := (&memoryCollector{}).Collect(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.Greater(t, payload.MemoryTotal, uint64(0))
+	assert.NotNil(t, payload.MemoryDetail)
+	assert.Greater(t, payload.MemoryDetail.Available, uint64(0))
+	assert.LessOrEqual(t, payload.MemoryDetail.Available, payload.MemoryTotal)
+}
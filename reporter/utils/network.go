@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	pNet "github.com/shirou/gopsutil/v4/net"
+	"math"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TrafficConfig struct {
+	CollectorOptions
+	Vnstat     bool   `json:"vnstat"` // use vnstat for total traffic, linux only
+	Interfaces Filter `json:"interfaces"`
+}
+
+/**
+ * InterfaceStats is the total counters of one network interface since boot
+ */
+type InterfaceStats struct {
+	Name        string   `json:"name"`
+	Addrs       []string `json:"addrs,omitempty"`
+	BytesRecv   uint64   `json:"bytes_recv"`
+	BytesSent   uint64   `json:"bytes_sent"`
+	PacketsRecv uint64   `json:"packets_recv"`
+	PacketsSent uint64   `json:"packets_sent"`
+	ErrIn       uint64   `json:"err_in"`
+	ErrOut      uint64   `json:"err_out"`
+	DropIn      uint64   `json:"drop_in"`
+	DropOut     uint64   `json:"drop_out"`
+}
+
+type trafficCollector struct {
+	vnstat     bool
+	interfaces Filter
+	prev       map[string]pNet.IOCountersStat
+	prevTime   time.Time
+}
+
+func newTrafficCollector(config TrafficConfig) *trafficCollector {
+	return &trafficCollector{
+		vnstat:     config.Vnstat,
+		interfaces: config.Interfaces,
+	}
+}
+
+func (c *trafficCollector) Name() string {
+	return "traffic"
+}
+
+func (c *trafficCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	counters, err := pNet.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	counters = c.filterInterfaces(counters)
+	netIn, netOut, netRx, netTx := c.getTraffic(counters, time.Now())
+
+	// Addresses are nice to have, the counters are still reported without them
+	interfaces, err := getInterfaceStats(ctx, counters)
+
+	if c.vnstat {
+		var vnstatErr error
+		netIn, netOut, vnstatErr = getTrafficVnstat(ctx)
+		if vnstatErr != nil {
+			err = fmt.Errorf("please check if the installation of vnStat is correct: %w", vnstatErr)
+		}
+	}
+
+	return func(payload *ReportDataPayload) {
+		payload.NetworkRx = netRx
+		payload.NetworkTx = netTx
+		payload.NetworkIn = netIn
+		payload.NetworkOut = netOut
+		payload.Interfaces = interfaces
+	}, err
+}
+
+func (c *trafficCollector) filterInterfaces(counters []pNet.IOCountersStat) []pNet.IOCountersStat {
+	result := counters[:0]
+	for _, v := range counters {
+		if c.interfaces.Match(v.Name, checkInterface) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+/**
+ * getTraffic returns the total traffic and the rates since the last call.
+ * Rates are summed per interface, so a reset of one interface or a new
+ * interface does not disturb the others. The first call has no rates.
+ */
+func (c *trafficCollector) getTraffic(counters []pNet.IOCountersStat, now time.Time) (uint64, uint64, uint64, uint64) {
+	var (
+		netIn, netOut uint64
+		recv, sent    uint64
+	)
+	current := make(map[string]pNet.IOCountersStat, len(counters))
+	for _, v := range counters {
+		netIn += v.BytesRecv
+		netOut += v.BytesSent
+		current[v.Name] = v
+
+		last, ok := c.prev[v.Name]
+		if !ok {
+			continue
+		}
+		if delta, ok := counterDelta(last.BytesRecv, v.BytesRecv); ok {
+			recv += delta
+		}
+		if delta, ok := counterDelta(last.BytesSent, v.BytesSent); ok {
+			sent += delta
+		}
+	}
+
+	// Use the real elapsed time, a tick can be delayed by a slow collection
+	elapsed := now.Sub(c.prevTime).Seconds()
+	first := c.prev == nil
+	c.prev = current
+	c.prevTime = now
+	if first || elapsed <= 0 {
+		return netIn, netOut, 0, 0
+	}
+
+	rx := uint64(float64(recv) / elapsed)
+	tx := uint64(float64(sent) / elapsed)
+	return netIn, netOut, rx, tx
+}
+
+// A 32-bit counter above this value which drops below it is taken as wrapped
+const counterWrapMargin = 1 << 30
+
+// The kernel keeps the interface counters in an unsigned long, which is only
+// 32-bit on 32-bit linux. Everywhere else they never wrap in practice.
+var counters32Bit = runtime.GOOS == "linux" && strconv.IntSize == 32
+
+/**
+ * counterDelta returns the increase of a counter between two samples. A
+ * 32-bit counter which was close to its limit has wrapped around, any other
+ * decrease means the counter was reset and the sample is skipped.
+ */
+func counterDelta(prev, current uint64) (uint64, bool) {
+	if current >= prev {
+		return current - prev, true
+	}
+	if counters32Bit && prev <= math.MaxUint32 && prev > math.MaxUint32-counterWrapMargin && current < counterWrapMargin {
+		return math.MaxUint32 - prev + current + 1, true
+	}
+	return 0, false
+}
+
+// getInterfaceStats pairs the counters with the addresses of each interface
+func getInterfaceStats(ctx context.Context, counters []pNet.IOCountersStat) ([]InterfaceStats, error) {
+	result := make([]InterfaceStats, 0, len(counters))
+	for _, v := range counters {
+		result = append(result, InterfaceStats{
+			Name:        v.Name,
+			BytesRecv:   v.BytesRecv,
+			BytesSent:   v.BytesSent,
+			PacketsRecv: v.PacketsRecv,
+			PacketsSent: v.PacketsSent,
+			ErrIn:       v.Errin,
+			ErrOut:      v.Errout,
+			DropIn:      v.Dropin,
+			DropOut:     v.Dropout,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	interfaces, err := pNet.InterfacesWithContext(ctx)
+	if err != nil {
+		return result, err
+	}
+	addrs := make(map[string][]string, len(interfaces))
+	for _, v := range interfaces {
+		for _, addr := range v.Addrs {
+			addrs[v.Name] = append(addrs[v.Name], addr.Addr)
+		}
+	}
+	for i := range result {
+		result[i].Addrs = addrs[result[i].Name]
+	}
+	return result, nil
+}
+
+func getTrafficVnstat(ctx context.Context) (uint64, uint64, error) {
+	buf, err := exec.CommandContext(ctx, "vnstat", "--oneline", "b").Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	vData := strings.Split(BytesToString(buf), ";")
+	if len(vData) != 15 {
+		// Not enough data available yet.
+		return 0, 0, nil
+	}
+	netIn, err := strconv.ParseUint(vData[8], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	netOut, err := strconv.ParseUint(vData[9], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return netIn, netOut, nil
+}
+
+// Loopback, tunnels and virtual interfaces of containers and VMs are not counted by default
+var invalidInterface = []string{"lo", "tun*", "kube*", "docker*", "vmbr*", "br-*", "vnet*", "veth*"}
+
+func checkInterface(name string) bool {
+	return !matchAny(invalidInterface, name)
+}
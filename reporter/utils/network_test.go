@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	pNet "github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCheckInterface(t *testing.T) {
+	assert.True(t, checkInterface("eth0"))
+	assert.True(t, checkInterface("wlo1"))
+	assert.True(t, checkInterface("enp3s0"))
+	assert.False(t, checkInterface("lo"))
+	assert.False(t, checkInterface("docker0"))
+	assert.False(t, checkInterface("veth1a2b3c"))
+	assert.False(t, checkInterface("br-0123456789ab"))
+	assert.False(t, checkInterface("tun0"))
+}
+
+func TestTrafficCollectorFilterInterfaces(t *testing.T) {
+	counters := func() []pNet.IOCountersStat {
+		return []pNet.IOCountersStat{
+			{Name: "lo"}, {Name: "eth0"}, {Name: "eth1"}, {Name: "docker0"}, {Name: "wg0"},
+		}
+	}
+	names := func(counters []pNet.IOCountersStat) []string {
+		result := []string{}
+		for _, v := range counters {
+			result = append(result, v.Name)
+		}
+		return result
+	}
+
+	collector := newTrafficCollector(TrafficConfig{})
+	assert.Equal(t, []string{"eth0", "eth1", "wg0"}, names(collector.filterInterfaces(counters())))
+
+	collector = newTrafficCollector(TrafficConfig{Interfaces: Filter{Exclude: []string{"wg*"}}})
+	assert.Equal(t, []string{"eth0", "eth1"}, names(collector.filterInterfaces(counters())))
+
+	collector = newTrafficCollector(TrafficConfig{Interfaces: Filter{Include: []string{"eth*", "docker0"}, Exclude: []string{"eth1"}}})
+	assert.Equal(t, []string{"eth0", "docker0"}, names(collector.filterInterfaces(counters())))
+}
+
+func TestGetInterfaceStats(t *testing.T) {
+	counters := []pNet.IOCountersStat{
+		{Name: "zz-missing", BytesRecv: 1},
+		{Name: "eth0", BytesRecv: 100, BytesSent: 200, PacketsRecv: 10, PacketsSent: 20, Errin: 1, Errout: 2, Dropin: 3, Dropout: 4},
+	}
+
+	stats, err := getInterfaceStats(context.Background(), counters)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, InterfaceStats{
+		Name:        "eth0",
+		Addrs:       stats[0].Addrs,
+		BytesRecv:   100,
+		BytesSent:   200,
+		PacketsRecv: 10,
+		PacketsSent: 20,
+		ErrIn:       1,
+		ErrOut:      2,
+		DropIn:      3,
+		DropOut:     4,
+	}, stats[0])
+	assert.Equal(t, "zz-missing", stats[1].Name)
+	assert.Empty(t, stats[1].Addrs)
+}
+
+func TestCounterDelta(t *testing.T) {
+	defer func(bits32 bool) { counters32Bit = bits32 }(counters32Bit)
+
+	delta, ok := counterDelta(100, 250)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(150), delta)
+
+	// Reset, e.g. the driver was reloaded
+	_, ok = counterDelta(5000, 100)
+	assert.False(t, ok)
+	_, ok = counterDelta(math.MaxUint32+5000, 100)
+	assert.False(t, ok)
+
+	// 64-bit counter reset close to 4 GiB, e.g. a vpn interface was recreated
+	counters32Bit = false
+	_, ok = counterDelta(math.MaxUint32-99, 50)
+	assert.False(t, ok)
+
+	// 32-bit counter wrapped around
+	counters32Bit = true
+	delta, ok = counterDelta(math.MaxUint32-99, 50)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(150), delta)
+}
+
+func TestTrafficCollectorRates(t *testing.T) {
+	collector := newTrafficCollector(TrafficConfig{})
+	start := time.Now()
+	sample := func(eth0Recv, eth0Sent, eth1Recv uint64) []pNet.IOCountersStat {
+		return []pNet.IOCountersStat{
+			{Name: "eth0", BytesRecv: eth0Recv, BytesSent: eth0Sent},
+			{Name: "eth1", BytesRecv: eth1Recv},
+		}
+	}
+
+	// Lifetime traffic must not show up as the rate of the first interval
+	netIn, netOut, rx, tx := collector.getTraffic(sample(1e9, 2e9, 1e6), start)
+	assert.Equal(t, uint64(1e9+1e6), netIn)
+	assert.Equal(t, uint64(2e9), netOut)
+	assert.Equal(t, uint64(0), rx)
+	assert.Equal(t, uint64(0), tx)
+
+	_, _, rx, tx = collector.getTraffic(sample(1e9+10000, 2e9+20000, 1e6+10000), start.Add(2*time.Second))
+	assert.Equal(t, uint64(10000), rx)
+	assert.Equal(t, uint64(10000), tx)
+
+	// eth1 was reset, only eth0 is counted for this interval
+	_, _, rx, tx = collector.getTraffic(sample(1e9+20000, 2e9+40000, 500), start.Add(4*time.Second))
+	assert.Equal(t, uint64(5000), rx)
+	assert.Equal(t, uint64(10000), tx)
+
+	// eth1 counts again from its new value
+	_, _, rx, _ = collector.getTraffic(sample(1e9+20000, 2e9+40000, 2500), start.Add(6*time.Second))
+	assert.Equal(t, uint64(1000), rx)
+
+	// A new interface starts without a rate
+	counters := append(sample(1e9+20000, 2e9+40000, 2500), pNet.IOCountersStat{Name: "eth2", BytesRecv: 1e12})
+	_, _, rx, _ = collector.getTraffic(counters, start.Add(8*time.Second))
+	assert.Equal(t, uint64(0), rx)
+
+	// eth0 was recreated close to 4 GiB, a 64-bit counter is reset, not wrapped
+	defer func(bits32 bool) { counters32Bit = bits32 }(counters32Bit)
+	counters32Bit = false
+	collector = newTrafficCollector(TrafficConfig{})
+	collector.getTraffic(sample(math.MaxUint32-999, 0, 0), start)
+	_, _, rx, _ = collector.getTraffic(sample(1000, 0, 0), start.Add(time.Second))
+	assert.Equal(t, uint64(0), rx)
+
+	// eth0 wrapped around a 32-bit counter
+	counters32Bit = true
+	collector = newTrafficCollector(TrafficConfig{})
+	collector.getTraffic(sample(math.MaxUint32-999, 0, 0), start)
+	_, _, rx, _ = collector.getTraffic(sample(1000, 0, 0), start.Add(time.Second))
+	assert.Equal(t, uint64(2000), rx)
+}
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+ * ProbesConfig lists checks run from this host, e.g. whether the database in
+ * the private network is reachable. The collector runs in the background on
+ * its own interval.
+ */
+type ProbesConfig struct {
+	CollectorOptions
+	Targets []ProbeTarget `json:"targets"`
+}
+
+/**
+ * ProbeTarget is one check, Address depends on the type:
+ *   icmp: host or ip
+ *   tcp:  host:port
+ *   dns:  the name to resolve, Server is the resolver host:port, the system
+ *         resolver is used when it is empty
+ *   http: url, a status below 400 is up
+ */
+type ProbeTarget struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Address string   `json:"address"`
+	Server  string   `json:"server"`
+	Timeout Duration `json:"timeout"` // 0 means the collector timeout
+}
+
+type ProbeResult struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Address    string  `json:"address"`
+	Status     string  `json:"status"`  // up, down or timeout
+	Latency    float64 `json:"latency"` // ms
+	StatusCode int     `json:"status_code,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type probeCollector struct {
+	config ProbesConfig
+	client *http.Client
+}
+
+func newProbeCollector(config ProbesConfig) *probeCollector {
+	return &probeCollector{
+		config: config,
+		client: &http.Client{},
+	}
+}
+
+func (c *probeCollector) Name() string {
+	return "probes"
+}
+
+func (c *probeCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	if len(c.config.Targets) == 0 {
+		return nil, nil
+	}
+
+	results := make([]ProbeResult, len(c.config.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.config.Targets {
+		wg.Add(1)
+		go func(i int, target ProbeTarget) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return func(payload *ReportDataPayload) {
+		payload.Probes = results
+	}, nil
+}
+
+func (c *probeCollector) probe(ctx context.Context, target ProbeTarget) ProbeResult {
+	result := ProbeResult{
+		Name:    target.Name,
+		Type:    target.Type,
+		Address: target.Address,
+	}
+	if result.Name == "" {
+		result.Name = target.Address
+	}
+
+	if target.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(target.Timeout))
+		defer cancel()
+	}
+
+	start := time.Now()
+	var err error
+	switch target.Type {
+	case "icmp":
+		err = probeICMP(ctx, target.Address)
+	case "tcp":
+		err = probeTCP(ctx, target.Address)
+	case "dns":
+		err = probeDNS(ctx, target.Address, target.Server)
+	case "http":
+		result.StatusCode, err = c.probeHTTP(ctx, target.Address)
+	default:
+		err = fmt.Errorf("unknown probe type %q", target.Type)
+	}
+
+	switch {
+	case err == nil:
+		result.Status = "up"
+		result.Latency = round1(float64(time.Since(start).Microseconds()) / 1000)
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		result.Status = "timeout"
+		result.Error = err.Error()
+	default:
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func probeTCP(ctx context.Context, address string) error {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeDNS(ctx context.Context, name string, server string) error {
+	resolver := net.DefaultResolver
+	if server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialer := &net.Dialer{}
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	addrs, err := resolver.LookupHost(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address for %s", name)
+	}
+	return nil
+}
+
+func (c *probeCollector) probeHTTP(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "tianji-reporter")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+/**
+ * probeICMP sends one echo request. It uses an unprivileged icmp socket
+ * first, which needs net.ipv4.ping_group_range on linux, and falls back to a
+ * raw socket when running as root.
+ */
+func probeICMP(ctx context.Context, host string) error {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	ip := ips[0]
+
+	var (
+		echoType   icmp.Type = ipv4.ICMPTypeEcho
+		replyType  icmp.Type = ipv4.ICMPTypeEchoReply
+		protocol             = 1
+		network              = "udp4"
+		rawNetwork           = "ip4:icmp"
+		listen               = "0.0.0.0"
+	)
+	if ip.To4() == nil {
+		echoType, replyType, protocol = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, 58
+		network, rawNetwork, listen = "udp6", "ip6:ipv6-icmp", "::"
+	}
+
+	privileged := false
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		var rawErr error
+		conn, rawErr = icmp.ListenPacket(rawNetwork, listen)
+		if rawErr != nil {
+			return fmt.Errorf("icmp is not permitted: %w", err)
+		}
+		privileged = true
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if privileged {
+		dst = &net.IPAddr{IP: ip}
+	}
+
+	// The kernel replaces the id of an unprivileged socket, match by seq
+	id := os.Getpid() & 0xffff
+	seq := rand.Intn(0xffff)
+	request, err := (&icmp.Message{
+		Type: echoType,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("tianji-reporter")},
+	}).Marshal(nil)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.WriteTo(request, dst); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(reply)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		msg, err := icmp.ParseMessage(protocol, reply[:n])
+		if err != nil || msg.Type != replyType {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+			continue
+		}
+		return nil
+	}
+}
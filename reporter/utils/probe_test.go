@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runProbes(t *testing.T, targets ...ProbeTarget) []ProbeResult {
+	collector := newProbeCollector(ProbesConfig{Targets: targets})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	merge, err := collector.Collect(ctx)
+	assert.NoError(t, err)
+	payload := ReportDataPayload{}
+	merge(&payload)
+	return payload.Probes
+}
+
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener.Close()
+	return listener.Addr().String()
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	results := runProbes(t,
+		ProbeTarget{Name: "db", Type: "tcp", Address: listener.Addr().String()},
+		ProbeTarget{Type: "tcp", Address: closedAddress(t)},
+	)
+	assert.Equal(t, "db", results[0].Name)
+	assert.Equal(t, "up", results[0].Status)
+	assert.Empty(t, results[0].Error)
+
+	assert.Equal(t, results[1].Address, results[1].Name)
+	assert.Equal(t, "down", results[1].Status)
+	assert.Contains(t, results[1].Error, "refused")
+}
+
+func TestProbeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("ok"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	results := runProbes(t,
+		ProbeTarget{Type: "http", Address: server.URL + "/ok"},
+		ProbeTarget{Type: "http", Address: server.URL + "/error"},
+		ProbeTarget{Type: "http", Address: server.URL + "/slow", Timeout: Duration(20 * time.Millisecond)},
+	)
+	assert.Equal(t, "up", results[0].Status)
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+
+	assert.Equal(t, "down", results[1].Status)
+	assert.Equal(t, http.StatusInternalServerError, results[1].StatusCode)
+
+	assert.Equal(t, "timeout", results[2].Status)
+	assert.Equal(t, 0.0, results[2].Latency)
+}
+
+// serveDNS answers A queries for example.internal. with 10.0.0.5
+func serveDNS(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, err := parser.Question()
+			if err != nil {
+				continue
+			}
+
+			builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true})
+			builder.EnableCompression()
+			builder.StartQuestions()
+			builder.Question(question)
+			builder.StartAnswers()
+			if question.Name.String() == "example.internal." && question.Type == dnsmessage.TypeA {
+				builder.AResource(
+					dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60},
+					dnsmessage.AResource{A: [4]byte{10, 0, 0, 5}},
+				)
+			}
+			msg, err := builder.Finish()
+			if err == nil {
+				conn.WriteTo(msg, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestProbeDNS(t *testing.T) {
+	server := serveDNS(t)
+
+	results := runProbes(t,
+		ProbeTarget{Type: "dns", Address: "example.internal", Server: server},
+		ProbeTarget{Type: "dns", Address: "missing.internal", Server: server},
+	)
+	assert.Equal(t, "up", results[0].Status)
+	assert.Equal(t, "down", results[1].Status)
+	assert.NotEmpty(t, results[1].Error)
+}
+
+func TestProbeICMP(t *testing.T) {
+	results := runProbes(t, ProbeTarget{Type: "icmp", Address: "127.0.0.1"})
+	if strings.Contains(results[0].Error, "not permitted") {
+		t.Skip("icmp is not permitted: ", results[0].Error)
+	}
+	assert.Equal(t, "up", results[0].Status, results[0].Error)
+}
+
+func TestProbeUnknownType(t *testing.T) {
+	results := runProbes(t, ProbeTarget{Type: "smtp", Address: "127.0.0.1:25"})
+	assert.Equal(t, "down", results[0].Status)
+	assert.Contains(t, results[0].Error, "unknown probe type")
+}
+
+func TestProbeNoTargets(t *testing.T) {
+	merge, err := newProbeCollector(ProbesConfig{}).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v4/process"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ProcessInfo struct {
+	PID    int32   `json:"pid"`
+	Name   string  `json:"name"`
+	CPU    float64 `json:"cpu"`
+	Memory uint64  `json:"memory"`
+}
+
+type ProcessesConfig struct {
+	CollectorOptions
+	Top     int            `json:"top"`      // number of top processes, 0 disables the lists
+	GroupBy string         `json:"group_by"` // also rank groups of processes by "name" or "user"
+	Watch   []ProcessWatch `json:"watch"`
+	// Arguments are redacted by the built-in patterns and these regexps
+	Redact   []string `json:"redact"`
+	NameOnly bool     `json:"name_only"` // send no arguments at all
+}
+
+/**
+ * ProcessGroup sums the processes with the same name or user, e.g. 40
+ * php-fpm workers
+ */
+type ProcessGroup struct {
+	Name   string  `json:"name"`
+	Count  int     `json:"count"`
+	CPU    float64 `json:"cpu"`
+	Memory uint64  `json:"memory"`
+}
+
+/**
+ * processHandle keeps a process between reports, so its cpu usage can be
+ * computed from the cpu time used since the last report
+ */
+type processHandle struct {
+	proc       *process.Process
+	createTime int64   // unix ms, tells a reused pid apart
+	cpuTime    float64 // user and system seconds at the last report, -1 before
+	cpu        float64 // percent of one core since the last report
+	name       string  // redacted name with arguments, the command line rarely changes
+	group      string
+}
+
+type processCollector struct {
+	top        int
+	groupBy    string
+	redactor   *redactor
+	err        error
+	watchers   []*processWatcher
+	handles    map[int32]*processHandle
+	lastSample time.Time
+}
+
+func newProcessCollector(config ProcessesConfig) *processCollector {
+	watchers := make([]*processWatcher, 0, len(config.Watch))
+	for _, watch := range config.Watch {
+		watchers = append(watchers, newProcessWatcher(watch))
+	}
+	redactor, err := newRedactor(config.Redact, config.NameOnly)
+	return &processCollector{
+		top:      config.Top,
+		groupBy:  config.GroupBy,
+		redactor: redactor,
+		err:      err,
+		watchers: watchers,
+	}
+}
+
+func (c *processCollector) Name() string {
+	return "processes"
+}
+
+func (c *processCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	handles, err := c.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var topCPUProcesses, topMemoryProcesses []ProcessInfo
+	if c.top > 0 {
+		topCPUProcesses = getTopCPUProcesses(ctx, handles, c.top, c.redactor)
+		topMemoryProcesses = getTopMemoryProcesses(ctx, handles, c.top, c.redactor)
+	}
+
+	var topCPUGroups, topMemoryGroups []ProcessGroup
+	var groupErr error
+	if c.groupBy != "" && c.top > 0 {
+		var groups []ProcessGroup
+		groups, groupErr = c.groupProcesses(ctx, handles)
+		topCPUGroups, topMemoryGroups = getTopGroups(groups, c.top)
+	}
+
+	watchedProcesses := c.watch(ctx, handles)
+
+	return func(payload *ReportDataPayload) {
+		payload.TopCPUProcesses = topCPUProcesses
+		payload.TopMemoryProcesses = topMemoryProcesses
+		payload.TopCPUGroups = topCPUGroups
+		payload.TopMemoryGroups = topMemoryGroups
+		payload.WatchedProcesses = watchedProcesses
+	}, errors.Join(c.err, groupErr)
+}
+
+/**
+ * refresh samples the cpu time of every process. A process seen for the
+ * first time, or whose pid now belongs to another process, has no usage
+ * until the next report.
+ */
+func (c *processCollector) refresh(ctx context.Context) ([]*processHandle, error) {
+	pids, err := process.PidsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastSample).Seconds()
+	handles := make(map[int32]*processHandle, len(pids))
+	result := make([]*processHandle, 0, len(pids))
+	for _, pid := range pids {
+		proc, err := process.NewProcessWithContext(ctx, pid)
+		if err != nil {
+			// Exited in the meantime
+			continue
+		}
+		createTime, err := proc.CreateTimeWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		handle, ok := c.handles[pid]
+		if !ok || handle.createTime != createTime {
+			handle = &processHandle{proc: proc, createTime: createTime, cpuTime: -1}
+		}
+
+		times, err := handle.proc.TimesWithContext(ctx)
+		if err != nil {
+			handle.cpu = 0
+		} else {
+			handle.sample(times.User+times.System, elapsed)
+		}
+
+		handles[pid] = handle
+		result = append(result, handle)
+	}
+
+	c.handles = handles
+	c.lastSample = now
+	return result, nil
+}
+
+func (h *processHandle) sample(cpuTime float64, elapsed float64) {
+	h.cpu = 0
+	if h.cpuTime >= 0 && elapsed > 0 && cpuTime >= h.cpuTime {
+		h.cpu = round1((cpuTime - h.cpuTime) / elapsed * 100)
+	}
+	h.cpuTime = cpuTime
+}
+
+// displayName returns the redacted name with arguments, cut to 100 characters
+func (h *processHandle) displayName(ctx context.Context, r *redactor) string {
+	if h.name != "" {
+		return h.name
+	}
+
+	name, _ := h.proc.NameWithContext(ctx)
+	if cmdlineSlice, _ := h.proc.CmdlineSliceWithContext(ctx); len(cmdlineSlice) > 1 && !r.nameOnly {
+		// Skip first argument (process name) and join the rest
+		args := strings.Join(cmdlineSlice[1:], " ")
+		if len(args) > 0 {
+			name = r.redact(name + " " + args)
+		}
+	}
+	// Limit total length to 100 characters, after redaction so no secret is cut in half
+	if len(name) > 100 {
+		name = name[:100] + "..."
+	}
+	h.name = name
+	return name
+}
+
+// rankedProcess keeps the handle, so only the names of the top ones are read
+type rankedProcess struct {
+	ProcessInfo
+	handle *processHandle
+}
+
+func getTopCPUProcesses(ctx context.Context, handles []*processHandle, n int, r *redactor) []ProcessInfo {
+	ranked := make([]rankedProcess, 0, len(handles))
+	for _, h := range handles {
+		if h.cpu == 0 {
+			continue
+		}
+		memInfo, _ := h.proc.MemoryInfoWithContext(ctx)
+		mem := uint64(0)
+		if memInfo != nil {
+			mem = memInfo.RSS / 1024
+		}
+		ranked = append(ranked, rankedProcess{
+			ProcessInfo: ProcessInfo{PID: h.proc.Pid, CPU: h.cpu, Memory: mem},
+			handle:      h,
+		})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].CPU > ranked[j].CPU
+	})
+	return topProcesses(ctx, ranked, n, r)
+}
+
+func getTopMemoryProcesses(ctx context.Context, handles []*processHandle, n int, r *redactor) []ProcessInfo {
+	ranked := make([]rankedProcess, 0, len(handles))
+	for _, h := range handles {
+		memInfo, err := h.proc.MemoryInfoWithContext(ctx)
+		if err != nil || memInfo == nil {
+			continue
+		}
+		mem := memInfo.RSS / 1024
+		if mem == 0 {
+			continue
+		}
+		ranked = append(ranked, rankedProcess{
+			ProcessInfo: ProcessInfo{PID: h.proc.Pid, CPU: h.cpu, Memory: mem},
+			handle:      h,
+		})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].Memory > ranked[j].Memory
+	})
+	return topProcesses(ctx, ranked, n, r)
+}
+
+func topProcesses(ctx context.Context, ranked []rankedProcess, n int, r *redactor) []ProcessInfo {
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	result := make([]ProcessInfo, 0, len(ranked))
+	for _, v := range ranked {
+		v.Name = v.handle.displayName(ctx, r)
+		result = append(result, v.ProcessInfo)
+	}
+	return result
+}
+
+func (c *processCollector) groupProcesses(ctx context.Context, handles []*processHandle) ([]ProcessGroup, error) {
+	if c.groupBy != "name" && c.groupBy != "user" {
+		return nil, fmt.Errorf("unknown group_by %q, should be name or user", c.groupBy)
+	}
+
+	groups := make(map[string]*ProcessGroup)
+	for _, h := range handles {
+		key := h.groupKey(ctx, c.groupBy)
+		if key == "" {
+			continue
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &ProcessGroup{Name: key}
+			groups[key] = group
+		}
+		group.Count++
+		group.CPU = round1(group.CPU + h.cpu)
+		if memInfo, err := h.proc.MemoryInfoWithContext(ctx); err == nil && memInfo != nil {
+			group.Memory += memInfo.RSS / 1024
+		}
+	}
+
+	result := make([]ProcessGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	return result, nil
+}
+
+// groupKey is the name or the user of the process, it is kept with the handle
+func (h *processHandle) groupKey(ctx context.Context, groupBy string) string {
+	if h.group != "" {
+		return h.group
+	}
+	if groupBy == "user" {
+		h.group, _ = h.proc.UsernameWithContext(ctx)
+	} else {
+		h.group, _ = h.proc.NameWithContext(ctx)
+	}
+	return h.group
+}
+
+func getTopGroups(groups []ProcessGroup, n int) ([]ProcessGroup, []ProcessGroup) {
+	byCPU := make([]ProcessGroup, 0, len(groups))
+	for _, group := range groups {
+		if group.CPU > 0 {
+			byCPU = append(byCPU, group)
+		}
+	}
+	sort.Slice(byCPU, func(i, j int) bool {
+		if byCPU[i].CPU != byCPU[j].CPU {
+			return byCPU[i].CPU > byCPU[j].CPU
+		}
+		return byCPU[i].Name < byCPU[j].Name
+	})
+
+	byMemory := make([]ProcessGroup, 0, len(groups))
+	for _, group := range groups {
+		if group.Memory > 0 {
+			byMemory = append(byMemory, group)
+		}
+	}
+	sort.Slice(byMemory, func(i, j int) bool {
+		if byMemory[i].Memory != byMemory[j].Memory {
+			return byMemory[i].Memory > byMemory[j].Memory
+		}
+		return byMemory[i].Name < byMemory[j].Name
+	})
+
+	if len(byCPU) > n {
+		byCPU = byCPU[:n]
+	}
+	if len(byMemory) > n {
+		byMemory = byMemory[:n]
+	}
+	return byCPU, byMemory
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProcessHandleSample(t *testing.T) {
+	handle := &processHandle{cpuTime: -1}
+
+	// No usage before the second sample, whatever the process used since start
+	handle.sample(5000, 5)
+	assert.Equal(t, 0.0, handle.cpu)
+
+	handle.sample(5002.5, 5)
+	assert.Equal(t, 50.0, handle.cpu)
+
+	// Multi threaded processes can use more than one core
+	handle.sample(5012.5, 5)
+	assert.Equal(t, 200.0, handle.cpu)
+
+	handle.sample(5012.5, 5)
+	assert.Equal(t, 0.0, handle.cpu)
+}
+
+func TestProcessCollectorCPU(t *testing.T) {
+	collector := newProcessCollector(ProcessesConfig{})
+	pid := int32(os.Getpid())
+	find := func(handles []*processHandle) *processHandle {
+		for _, h := range handles {
+			if h.proc.Pid == pid {
+				return h
+			}
+		}
+		return nil
+	}
+
+	handles, err := collector.refresh(context.Background())
+	assert.NoError(t, err)
+	first := find(handles)
+	assert.NotNil(t, first)
+	assert.Equal(t, 0.0, first.cpu)
+
+	// Keep one core busy until the next sample
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+
+	handles, err = collector.refresh(context.Background())
+	assert.NoError(t, err)
+	second := find(handles)
+	assert.Same(t, first, second, "The handle should be reused")
+	assert.Greater(t, second.cpu, 50.0)
+
+	found := false
+	for _, v := range getTopCPUProcesses(context.Background(), handles, len(handles), collector.redactor) {
+		if v.PID == pid {
+			found = true
+			assert.Equal(t, second.cpu, v.CPU)
+			assert.NotEmpty(t, v.Name)
+		}
+	}
+	assert.True(t, found, "Should list the test process")
+}
+
+func TestProcessCollectorPIDReuse(t *testing.T) {
+	pid := int32(os.Getpid())
+	stale, err := process.NewProcess(pid)
+	assert.NoError(t, err)
+
+	// The pid belonged to another process which used a lot of cpu time
+	collector := newProcessCollector(ProcessesConfig{})
+	collector.handles = map[int32]*processHandle{
+		pid: {proc: stale, createTime: 1, cpuTime: 0, name: "old-daemon"},
+	}
+	collector.lastSample = time.Now().Add(-time.Second)
+
+	handles, err := collector.refresh(context.Background())
+	assert.NoError(t, err)
+	for _, h := range handles {
+		if h.proc.Pid == pid {
+			assert.NotEqual(t, int64(1), h.createTime)
+			assert.Equal(t, 0.0, h.cpu)
+			assert.NotEqual(t, "old-daemon", h.displayName(context.Background(), collector.redactor))
+		}
+	}
+}
+
+func TestGetTopGroups(t *testing.T) {
+	groups := []ProcessGroup{
+		{Name: "php-fpm", Count: 40, CPU: 12, Memory: 2000000},
+		{Name: "mysqld", Count: 1, CPU: 30, Memory: 800000},
+		{Name: "sshd", Count: 3, Memory: 9000},
+		{Name: "nginx", Count: 5, CPU: 2, Memory: 50000},
+	}
+
+	byCPU, byMemory := getTopGroups(groups, 2)
+	assert.Equal(t, []string{"mysqld", "php-fpm"}, []string{byCPU[0].Name, byCPU[1].Name})
+	assert.Equal(t, []string{"php-fpm", "mysqld"}, []string{byMemory[0].Name, byMemory[1].Name})
+
+	// Idle groups are not ranked by cpu
+	byCPU, byMemory = getTopGroups(groups, 10)
+	assert.Len(t, byCPU, 3)
+	assert.Len(t, byMemory, 4)
+}
+
+func TestProcessCollectorGroups(t *testing.T) {
+	for _, arg := range []string{"3101", "3102", "3103"} {
+		startTestProcess(t, arg)
+	}
+
+	collector := newProcessCollector(ProcessesConfig{Top: 50, GroupBy: "name"})
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.LessOrEqual(t, len(payload.TopMemoryProcesses), 50)
+
+	found := false
+	for _, group := range payload.TopMemoryGroups {
+		if group.Name == "sleep" {
+			found = true
+			assert.GreaterOrEqual(t, group.Count, 3)
+			assert.Greater(t, group.Memory, uint64(0))
+		}
+	}
+	assert.True(t, found, "Should group the sleep processes")
+
+	// Lists are disabled without top
+	collector = newProcessCollector(ProcessesConfig{GroupBy: "name"})
+	merge, err = collector.Collect(context.Background())
+	assert.NoError(t, err)
+	payload = ReportDataPayload{}
+	merge(&payload)
+	assert.Nil(t, payload.TopMemoryProcesses)
+	assert.Nil(t, payload.TopMemoryGroups)
+
+	collector = newProcessCollector(ProcessesConfig{Top: 3, GroupBy: "exe"})
+	merge, err = collector.Collect(context.Background())
+	assert.ErrorContains(t, err, "unknown group_by")
+	payload = ReportDataPayload{}
+	merge(&payload)
+	assert.NotEmpty(t, payload.TopMemoryProcesses)
+}
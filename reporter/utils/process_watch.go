@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/shirou/gopsutil/v4/process"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * ProcessWatch selects the processes of one service, every rule which is
+ * set must match:
+ *   process: the process name, e.g. nginx
+ *   cmdline: a regexp on the full command line
+ *   pidfile: the file with the pid of the main process
+ *   user:    the owner of the process
+ */
+type ProcessWatch struct {
+	Name    string `json:"name"`
+	Process string `json:"process"`
+	Cmdline string `json:"cmdline"`
+	Pidfile string `json:"pidfile"`
+	User    string `json:"user"`
+}
+
+/**
+ * WatchedProcess sums all instances of a watched process, a service without
+ * any instance is down
+ */
+type WatchedProcess struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // up or down
+	Instances int     `json:"instances"`
+	PIDs      []int32 `json:"pids,omitempty"`
+	CPU       float64 `json:"cpu"`
+	Memory    uint64  `json:"memory"` // RSS, KB
+	FDs       int32   `json:"fds"`
+	Threads   int32   `json:"threads"`
+	Uptime    uint64  `json:"uptime"` // seconds since the oldest instance started
+	Error     string  `json:"error,omitempty"`
+}
+
+type processWatcher struct {
+	watch   ProcessWatch
+	cmdline *regexp.Regexp
+	err     error
+}
+
+func newProcessWatcher(watch ProcessWatch) *processWatcher {
+	watcher := &processWatcher{watch: watch}
+	for _, name := range []string{watch.Process, watch.Pidfile, watch.Cmdline, watch.User} {
+		if watcher.watch.Name == "" {
+			watcher.watch.Name = name
+		}
+	}
+	if watch.Cmdline != "" {
+		watcher.cmdline, watcher.err = regexp.Compile(watch.Cmdline)
+	}
+	if watch.Process == "" && watch.Cmdline == "" && watch.Pidfile == "" && watch.User == "" {
+		watcher.err = fmt.Errorf("no rule to match the process")
+	}
+	return watcher
+}
+
+func (c *processCollector) watch(ctx context.Context, handles []*processHandle) []WatchedProcess {
+	if len(c.watchers) == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	result := make([]WatchedProcess, 0, len(c.watchers))
+	for _, watcher := range c.watchers {
+		watched := WatchedProcess{Name: watcher.watch.Name}
+		pid, err := watcher.pidfilePID()
+		if err == nil {
+			err = watcher.err
+		}
+		if err != nil {
+			watched.Status = "down"
+			watched.Error = err.Error()
+			result = append(result, watched)
+			continue
+		}
+
+		for _, h := range handles {
+			if pid != 0 && h.proc.Pid != pid {
+				continue
+			}
+			if !watcher.match(ctx, h.proc) {
+				continue
+			}
+			watched.add(ctx, h, now)
+		}
+
+		watched.Status = "down"
+		if watched.Instances > 0 {
+			watched.Status = "up"
+		}
+		result = append(result, watched)
+	}
+	return result
+}
+
+// pidfilePID returns 0 when the watch has no pidfile
+func (w *processWatcher) pidfilePID() (int32, error) {
+	if w.watch.Pidfile == "" {
+		return 0, nil
+	}
+	buf, err := os.ReadFile(w.watch.Pidfile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(buf)), 10, 32)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid in %s", w.watch.Pidfile)
+	}
+	return int32(pid), nil
+}
+
+func (w *processWatcher) match(ctx context.Context, p *process.Process) bool {
+	if w.watch.Process != "" {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			return false
+		}
+		if name != w.watch.Process {
+			// The name is cut to 15 characters on linux, try the executable
+			cmdline, _ := p.CmdlineSliceWithContext(ctx)
+			if len(cmdline) == 0 || filepath.Base(cmdline[0]) != w.watch.Process {
+				return false
+			}
+		}
+	}
+
+	if w.cmdline != nil {
+		cmdline, err := p.CmdlineWithContext(ctx)
+		if err != nil || !w.cmdline.MatchString(cmdline) {
+			return false
+		}
+	}
+
+	if w.watch.User != "" {
+		user, err := p.UsernameWithContext(ctx)
+		if err != nil || user != w.watch.User {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add an instance, metrics which can not be read, e.g. fds of another user, are skipped
+func (w *WatchedProcess) add(ctx context.Context, h *processHandle, now int64) {
+	p := h.proc
+	w.Instances++
+	w.PIDs = append(w.PIDs, p.Pid)
+	w.CPU = round1(w.CPU + h.cpu)
+	if memInfo, err := p.MemoryInfoWithContext(ctx); err == nil && memInfo != nil {
+		w.Memory += memInfo.RSS / 1024
+	}
+	if fds, err := p.NumFDsWithContext(ctx); err == nil {
+		w.FDs += fds
+	}
+	if threads, err := p.NumThreadsWithContext(ctx); err == nil {
+		w.Threads += threads
+	}
+	if now > h.createTime {
+		uptime := uint64((now - h.createTime) / 1000)
+		if uptime > w.Uptime {
+			w.Uptime = uptime
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func startTestProcess(t *testing.T, arg string) *exec.Cmd {
+	cmd := exec.Command("sleep", arg)
+	if err := cmd.Start(); err != nil {
+		t.Skip("can not start sleep: ", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestProcessWatch(t *testing.T) {
+	first := startTestProcess(t, "3001")
+	second := startTestProcess(t, "3002")
+
+	pidfile := filepath.Join(t.TempDir(), "sleep.pid")
+	assert.NoError(t, os.WriteFile(pidfile, []byte(strconv.Itoa(second.Process.Pid)+"\n"), 0600))
+	current, err := user.Current()
+	assert.NoError(t, err)
+
+	collector := newProcessCollector(ProcessesConfig{Watch: []ProcessWatch{
+		{Name: "sleepers", Process: "sleep", Cmdline: `^sleep 300[12]$`},
+		{Pidfile: pidfile},
+		{Name: "mine", Cmdline: `^sleep 3001$`, User: current.Username},
+		{Name: "others", Cmdline: `^sleep 3001$`, User: "nobody-" + current.Username},
+		{Name: "gone", Process: "tianji-missing-daemon"},
+		{Name: "broken", Cmdline: `sleep (`},
+		{Name: "stale", Pidfile: filepath.Join(t.TempDir(), "missing.pid")},
+		{Name: "empty"},
+	}})
+
+	handles, err := collector.refresh(context.Background())
+	assert.NoError(t, err)
+	watched := collector.watch(context.Background(), handles)
+	assert.Len(t, watched, 8)
+
+	sleepers := watched[0]
+	assert.Equal(t, "up", sleepers.Status)
+	assert.Equal(t, 2, sleepers.Instances)
+	assert.ElementsMatch(t, []int32{int32(first.Process.Pid), int32(second.Process.Pid)}, sleepers.PIDs)
+	assert.Greater(t, sleepers.Memory, uint64(0))
+	assert.Greater(t, sleepers.Threads, int32(0))
+	assert.Greater(t, sleepers.FDs, int32(0))
+
+	assert.Equal(t, pidfile, watched[1].Name)
+	assert.Equal(t, "up", watched[1].Status)
+	assert.Equal(t, []int32{int32(second.Process.Pid)}, watched[1].PIDs)
+
+	assert.Equal(t, "up", watched[2].Status)
+	assert.Equal(t, []int32{int32(first.Process.Pid)}, watched[2].PIDs)
+
+	for _, down := range watched[3:] {
+		assert.Equal(t, "down", down.Status, down.Name)
+		assert.Equal(t, 0, down.Instances, down.Name)
+	}
+	assert.Empty(t, watched[4].Error)
+	assert.Contains(t, watched[5].Error, "missing closing )")
+	assert.NotEmpty(t, watched[6].Error)
+	assert.Equal(t, "no rule to match the process", watched[7].Error)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/sensors"
+	"sort"
+)
+
+/**
+ * SensorTemperature is one hardware temperature sensor in celsius, High and
+ * Critical are 0 when the sensor has no threshold
+ */
+type SensorTemperature struct {
+	Key         string  `json:"key"`
+	Temperature float64 `json:"temperature"`
+	High        float64 `json:"high"`
+	Critical    float64 `json:"critical"`
+}
+
+func collectSensors(ctx context.Context) (MergeFunc, error) {
+	// Unreadable sensors come back as warnings next to the readable ones
+	temperatures, err := sensors.TemperaturesWithContext(ctx)
+	if len(temperatures) == 0 {
+		return nil, err
+	}
+
+	result := make([]SensorTemperature, 0, len(temperatures))
+	for _, v := range temperatures {
+		result = append(result, SensorTemperature{
+			Key:         v.SensorKey,
+			Temperature: round1(v.Temperature),
+			High:        round1(v.High),
+			Critical:    round1(v.Critical),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return func(payload *ReportDataPayload) {
+		payload.Sensors = result
+	}, err
+}
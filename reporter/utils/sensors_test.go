@@ -0,0 +1,88 @@
+//go:build linux
+
+package utils
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v4/common"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSysFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func collectTestSensors(t *testing.T, sys string) ([]SensorTemperature, error) {
+	ctx := context.WithValue(context.Background(), common.EnvKey, common.EnvMap{common.HostSysEnvKey: sys})
+	merge, err := collectSensors(ctx)
+	payload := ReportDataPayload{}
+	if merge != nil {
+		merge(&payload)
+	}
+	return payload.Sensors, err
+}
+
+func TestCollectSensorsHwmon(t *testing.T) {
+	sys := t.TempDir()
+	writeSysFiles(t, sys, map[string]string{
+		"class/hwmon/hwmon0/name":        "coretemp\n",
+		"class/hwmon/hwmon0/temp1_label": "Package id 0\n",
+		"class/hwmon/hwmon0/temp1_input": "54000\n",
+		"class/hwmon/hwmon0/temp1_max":   "80000\n",
+		"class/hwmon/hwmon0/temp1_crit":  "100000\n",
+		"class/hwmon/hwmon0/temp2_label": "Core 0\n",
+		"class/hwmon/hwmon0/temp2_input": "51250\n",
+		"class/hwmon/hwmon1/name":        "nvme\n",
+		"class/hwmon/hwmon1/temp1_input": "38850\n",
+	})
+
+	sensors, err := collectTestSensors(t, sys)
+	assert.NoError(t, err)
+	assert.Equal(t, []SensorTemperature{
+		{Key: "coretemp_core_0", Temperature: 51.3},
+		{Key: "coretemp_package_id_0", Temperature: 54, High: 80, Critical: 100},
+		{Key: "nvme", Temperature: 38.9},
+	}, sensors)
+}
+
+func TestCollectSensorsThermalZone(t *testing.T) {
+	sys := t.TempDir()
+	writeSysFiles(t, sys, map[string]string{
+		"class/thermal/thermal_zone0/type": "cpu-thermal\n",
+		"class/thermal/thermal_zone0/temp": "47236\n",
+	})
+
+	sensors, err := collectTestSensors(t, sys)
+	assert.NoError(t, err)
+	assert.Equal(t, []SensorTemperature{
+		{Key: "cpu-thermal", Temperature: 47.2},
+	}, sensors)
+}
+
+func TestCollectSensorsPartial(t *testing.T) {
+	sys := t.TempDir()
+	writeSysFiles(t, sys, map[string]string{
+		"class/hwmon/hwmon0/name":        "acpitz\n",
+		"class/hwmon/hwmon0/temp1_input": "27800\n",
+		"class/hwmon/hwmon0/temp2_input": "broken\n",
+	})
+
+	sensors, err := collectTestSensors(t, sys)
+	assert.Error(t, err)
+	assert.Equal(t, []SensorTemperature{
+		{Key: "acpitz", Temperature: 27.8},
+	}, sensors)
+}
+
+func TestCollectSensorsNone(t *testing.T) {
+	sensors, err := collectTestSensors(t, t.TempDir())
+	assert.NoError(t, err)
+	assert.Empty(t, sensors)
+}
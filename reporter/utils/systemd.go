@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/v4/host"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/**
+ * SystemdConfig lists the units to watch, e.g. nginx.service. With Failed
+ * every failed unit is reported too.
+ */
+type SystemdConfig struct {
+	CollectorOptions
+	Units  []string `json:"units"`
+	Failed bool     `json:"failed"`
+}
+
+type SystemdUnit struct {
+	Name        string `json:"name"`
+	LoadState   string `json:"load_state"`   // loaded, not-found...
+	ActiveState string `json:"active_state"` // active, failed, activating...
+	SubState    string `json:"sub_state"`    // running, dead, exited...
+	Restarts    int    `json:"restarts"`
+	StateChange uint64 `json:"state_change,omitempty"` // unix seconds
+}
+
+// commandRunner runs a command and returns its stdout, replaced in tests
+type commandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	buf, err := exec.CommandContext(ctx, name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return buf, fmt.Errorf("%s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return buf, err
+}
+
+type systemdCollector struct {
+	config   SystemdConfig
+	run      commandRunner
+	bootTime func(ctx context.Context) (uint64, error)
+}
+
+func newSystemdCollector(config SystemdConfig) *systemdCollector {
+	return &systemdCollector{
+		config:   config,
+		run:      runCommand,
+		bootTime: host.BootTimeWithContext,
+	}
+}
+
+func (c *systemdCollector) Name() string {
+	return "systemd"
+}
+
+func (c *systemdCollector) Collect(ctx context.Context) (MergeFunc, error) {
+	if len(c.config.Units) == 0 && !c.config.Failed {
+		return nil, nil
+	}
+
+	units := append([]string{}, c.config.Units...)
+	if c.config.Failed {
+		failed, err := c.failedUnits(ctx)
+		if errors.Is(err, exec.ErrNotFound) {
+			// Not a systemd machine
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, unit := range failed {
+			if !slices.Contains(units, unit) {
+				units = append(units, unit)
+			}
+		}
+	}
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	args := []string{"show", "--property=Id,LoadState,ActiveState,SubState,NRestarts,StateChangeTimestampMonotonic", "--"}
+	buf, err := c.run(ctx, "systemctl", append(args, units...)...)
+	if errors.Is(err, exec.ErrNotFound) {
+		// Not a systemd machine
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The state change is in microseconds since boot
+	bootTime, err := c.bootTime(ctx)
+	result := parseSystemdShow(buf, units, bootTime)
+
+	return func(payload *ReportDataPayload) {
+		payload.SystemdUnits = result
+	}, err
+}
+
+func (c *systemdCollector) failedUnits(ctx context.Context) ([]string, error) {
+	buf, err := c.run(ctx, "systemctl", "list-units", "--state=failed", "--no-legend", "--plain", "--full")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units, scanner.Err()
+}
+
+/**
+ * parseSystemdShow parses the key=value blocks of systemctl show, one block
+ * per unit separated by an empty line, in the order of units
+ */
+func parseSystemdShow(buf []byte, units []string, bootTime uint64) []SystemdUnit {
+	result := make([]SystemdUnit, 0, len(units))
+	blocks := strings.Split(strings.TrimSpace(BytesToString(buf)), "\n\n")
+	for i, block := range blocks {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+
+		unit := SystemdUnit{}
+		if i < len(units) {
+			unit.Name = units[i]
+		}
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Id":
+				if value != "" {
+					unit.Name = value
+				}
+			case "LoadState":
+				unit.LoadState = value
+			case "ActiveState":
+				unit.ActiveState = value
+			case "SubState":
+				unit.SubState = value
+			case "NRestarts":
+				unit.Restarts, _ = strconv.Atoi(value)
+			case "StateChangeTimestampMonotonic":
+				if usec, err := strconv.ParseUint(value, 10, 64); err == nil && usec > 0 && bootTime > 0 {
+					unit.StateChange = bootTime + usec/1000000
+				}
+			}
+		}
+		result = append(result, unit)
+	}
+	return result
+}
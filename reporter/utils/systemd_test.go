@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const testSystemctlShow = `Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+NRestarts=2
+StateChangeTimestampMonotonic=120000000
+
+Id=missing.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+NRestarts=0
+StateChangeTimestampMonotonic=0
+
+Id=app.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+NRestarts=5
+StateChangeTimestampMonotonic=3600500000
+`
+
+// fakeSystemctl answers like systemctl and records the commands
+type fakeSystemctl struct {
+	commands []string
+	failed   string
+	err      error
+}
+
+func (f *fakeSystemctl) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := name + " " + strings.Join(args, " ")
+	f.commands = append(f.commands, command)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if args[0] == "list-units" {
+		return []byte(f.failed), nil
+	}
+	return []byte(testSystemctlShow), nil
+}
+
+func newTestSystemdCollector(config SystemdConfig, fake *fakeSystemctl) *systemdCollector {
+	collector := newSystemdCollector(config)
+	collector.run = fake.run
+	collector.bootTime = func(ctx context.Context) (uint64, error) {
+		return 1700000000, nil
+	}
+	return collector
+}
+
+func TestSystemdCollector(t *testing.T) {
+	fake := &fakeSystemctl{failed: "app.service loaded failed failed My App\nnginx.service loaded failed failed nginx\n"}
+	collector := newTestSystemdCollector(SystemdConfig{
+		Units:  []string{"nginx.service", "missing.service"},
+		Failed: true,
+	}, fake)
+
+	merge, err := collector.Collect(context.Background())
+	assert.NoError(t, err)
+	payload := ReportDataPayload{}
+	merge(&payload)
+
+	assert.Equal(t, []string{
+		"systemctl list-units --state=failed --no-legend --plain --full",
+		"systemctl show --property=Id,LoadState,ActiveState,SubState,NRestarts,StateChangeTimestampMonotonic -- nginx.service missing.service app.service",
+	}, fake.commands)
+
+	assert.Equal(t, []SystemdUnit{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 2, StateChange: 1700000120},
+		{Name: "missing.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+		{Name: "app.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 5, StateChange: 1700003600},
+	}, payload.SystemdUnits)
+}
+
+func TestSystemdCollectorNothingToWatch(t *testing.T) {
+	fake := &fakeSystemctl{}
+
+	merge, err := newTestSystemdCollector(SystemdConfig{}, fake).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+	assert.Empty(t, fake.commands)
+
+	// No failed units
+	merge, err = newTestSystemdCollector(SystemdConfig{Failed: true}, fake).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+	assert.Len(t, fake.commands, 1)
+}
+
+func TestSystemdCollectorNoSystemd(t *testing.T) {
+	fake := &fakeSystemctl{err: &exec.Error{Name: "systemctl", Err: exec.ErrNotFound}}
+
+	merge, err := newTestSystemdCollector(SystemdConfig{Failed: true}, fake).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+
+	// The same config is shipped to every host, units are skipped without systemd
+	merge, err = newTestSystemdCollector(SystemdConfig{Units: []string{"nginx.service"}}, fake).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+
+	merge, err = newTestSystemdCollector(SystemdConfig{Units: []string{"nginx.service"}, Failed: true}, fake).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, merge)
+}
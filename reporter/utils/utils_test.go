@@ -1,20 +1,32 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestGetPayload(t *testing.T) {
-	payload := GetReportDataPaylod(5, false)
+	payload := NewDefaultRegistry(DefaultCollectorsConfig()).Collect(context.Background())
 
 	fmt.Println("{}", payload)
 }
 
 func TestGetDockerStat(t *testing.T) {
-	dockerPayloads, err := GetDockerStat()
+	dockerPayloads, err := GetDockerStat(context.Background())
 	assert.NoError(t, err, "Should can get docker stat")
 
 	fmt.Println("{}", dockerPayloads)
 }
+
+func TestCollectLoad(t *testing.T) {
+	merge, err := collectLoad(context.Background())
+	assert.NoError(t, err)
+
+	payload := ReportDataPayload{}
+	merge(&payload)
+	assert.NotNil(t, payload.LoadDetail)
+	assert.Equal(t, fmt.Sprintf("%.2f", payload.LoadDetail.Load1), payload.Load.String())
+	assert.Greater(t, payload.LoadDetail.ProcsTotal, 0)
+}
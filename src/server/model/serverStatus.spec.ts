@@ -0,0 +1,119 @@
+import { beforeEach, describe, expect, test, vi } from 'vitest';
+import {
+  ServerStatusHostInfo,
+  ServerStatusInfo,
+} from '../../types/index.js';
+import {
+  getServerMapFromCache,
+  getServerStatusHistory,
+  recordServerStatus,
+} from './serverStatus.js';
+
+const cache = vi.hoisted(() => new Map<string, unknown>());
+
+vi.mock('../cache/index.js', () => ({
+  getCacheManager: async () => ({
+    get: async (key: string) => cache.get(key),
+    set: async (key: string, value: unknown) => {
+      cache.set(key, value);
+    },
+  }),
+}));
+
+vi.mock('../utils/prometheus/client.js', () => ({
+  promServerCounter: {
+    set: vi.fn(),
+  },
+}));
+
+vi.mock('../ws/shared.js', () => ({
+  createSubscribeInitializer: vi.fn(),
+  subscribeEventBus: {
+    emit: vi.fn(),
+  },
+}));
+
+function createReport(
+  timestamp: number,
+  extra: Partial<ServerStatusInfo> = {}
+): ServerStatusInfo {
+  return {
+    workspaceId: 'workspace',
+    name: 'web-01',
+    hostname: 'web-01',
+    timeout: 50,
+    updatedAt: 0,
+    timestamp,
+    payload: {
+      uptime: timestamp,
+    } as ServerStatusInfo['payload'],
+    ...extra,
+  };
+}
+
+async function flushHistory() {
+  // history is saved without waiting for it
+  await new Promise((resolve) => setTimeout(resolve, 0));
+}
+
+describe('recordServerStatus', () => {
+  beforeEach(() => {
+    cache.clear();
+  });
+
+  test('replayed report does not replace the current status', async () => {
+    await recordServerStatus(createReport(3000));
+    await flushHistory();
+    await recordServerStatus(createReport(1000, { replay: true }));
+    await flushHistory();
+
+    const serverMap = await getServerMapFromCache('workspace');
+    expect(serverMap['web-01'].timestamp).toBe(3000);
+
+    const history = await getServerStatusHistory('workspace', 'web-01');
+    expect(history.map((item) => item.timestamp)).toEqual([1000, 3000]);
+    expect(history[1].updatedAt - history[0].updatedAt).toBe(2000);
+  });
+
+  test('live report after the host clock stepped back is current', async () => {
+    await recordServerStatus(createReport(3000));
+    await recordServerStatus(createReport(1000));
+
+    const serverMap = await getServerMapFromCache('workspace');
+    expect(serverMap['web-01'].timestamp).toBe(1000);
+  });
+
+  test('replayed report older than the history window is dropped', async () => {
+    for (let i = 0; i < 20; i++) {
+      await recordServerStatus(createReport(2000 + i));
+      await flushHistory();
+    }
+    await recordServerStatus(createReport(1000, { replay: true }));
+    await flushHistory();
+
+    const history = await getServerStatusHistory('workspace', 'web-01');
+    expect(history).toHaveLength(20);
+    expect(history[0].timestamp).toBe(2000);
+  });
+
+  test('host info is kept until the next one is sent', async () => {
+    const hostInfo = {
+      os: 'linux',
+      platform: 'ubuntu',
+      platform_version: '24.04',
+    } as ServerStatusHostInfo;
+    await recordServerStatus(
+      createReport(1000, {
+        payload: {
+          uptime: 1000,
+          host_info: hostInfo,
+        } as ServerStatusInfo['payload'],
+      })
+    );
+    await recordServerStatus(createReport(2000));
+
+    const serverMap = await getServerMapFromCache('workspace');
+    expect(serverMap['web-01'].timestamp).toBe(2000);
+    expect(serverMap['web-01'].payload.host_info).toEqual(hostInfo);
+  });
+});
@@ -98,7 +98,8 @@ export async function recordServerStatus(
   info: ServerStatusInfo,
   requestContext: ServerStatusRequestContext = {}
 ) {
-  const { workspaceId, name, hostname, timeout, payload } = info;
+  const { workspaceId, name, hostname, timeout, timestamp, replay, payload } =
+    info;
 
   if (!workspaceId || !name || !hostname) {
     console.warn(
@@ -111,36 +112,68 @@ export async function recordServerStatus(
   // Get current server map from cache
   const serverMap = await getServerMapFromCache(workspaceId);
 
-  // Update current server status
   const serverKey = name || hostname;
-  serverMap[serverKey] = {
+  const current = serverMap[serverKey];
+
+  // Reports replayed from the reporter spool never replace the current
+  // status. Their timestamp is converted to server time based on the current
+  // report, so a clock drift of the reporter does not matter
+  const isReplay = replay === true;
+  let updatedAt = Date.now();
+  if (isReplay && timestamp) {
+    updatedAt = current?.timestamp
+      ? current.updatedAt - (current.timestamp - timestamp)
+      : timestamp;
+  }
+
+  const status: ServerStatusInfo = {
     workspaceId,
     name,
     hostname,
     timeout,
-    updatedAt: Date.now(),
+    updatedAt,
+    timestamp,
     payload: {
       ...requestContext,
       ...payload,
     },
   };
 
-  // Save updated server map to cache
-  await saveServerMapToCache(workspaceId, serverMap);
+  // The reporter only sends the host info now and then, keep the last one
+  if (!isReplay && !status.payload.host_info && current?.payload.host_info) {
+    status.payload.host_info = current.payload.host_info;
+  }
+
+  if (!isReplay) {
+    // Update current server status
+    serverMap[serverKey] = status;
 
-  // Update server history using cache
+    // Save updated server map to cache
+    await saveServerMapToCache(workspaceId, serverMap);
+  }
+
+  // Update server history using cache, ordered by the time of the report
   const history = await getServerHistoryFromCache(workspaceId, serverKey);
-  history.push(serverMap[serverKey]);
+  const index = history.findIndex((item) => item.updatedAt > updatedAt);
+  if (index === -1) {
+    history.push(status);
+  } else {
+    history.splice(index, 0, status);
+  }
 
   // Keep only the last 20 records
   if (history.length > 20) {
-    history.shift();
+    history.splice(0, history.length - 20);
   }
 
   saveServerHistoryToCache(workspaceId, serverKey, history).catch((err) => {
     logger.error('[ServerStatus] Error saving history to cache:', err);
   });
 
+  if (isReplay) {
+    return;
+  }
+
   promServerCounter.set(
     {
       workspaceId,
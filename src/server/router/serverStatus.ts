@@ -17,6 +17,8 @@ serverStatusRouter.post(
     body('name').isString(),
     body('hostname').isString(),
     body('timeout').optional().isInt(),
+    body('timestamp').optional().isInt(),
+    body('replay').optional().isBoolean(),
     body('payload').isObject()
   ),
   async (req, res) => {
@@ -6,6 +6,14 @@ export interface ServerStatusInfo {
   hostname: string;
   timeout: number;
   updatedAt: number;
+  /**
+   * unix milliseconds when the report was collected, in the reporter clock
+   */
+  timestamp?: number;
+  /**
+   * set by the reporter when the report is replayed from its spool
+   */
+  replay?: boolean;
   payload: ServerStatusInfoPayload & ServerStatusRequestContext;
 }
 
@@ -29,6 +37,24 @@ export interface ServerStatusInfoPayload {
 
   // docker info
   docker?: ServerStatusDockerContainerPayload[];
+
+  // only sent now and then, the server keeps the last one
+  host_info?: ServerStatusHostInfo;
+}
+
+export interface ServerStatusHostInfo {
+  os: string;
+  platform: string;
+  platform_family: string;
+  platform_version: string;
+  kernel_version: string;
+  kernel_arch: string;
+  cpu_model: string;
+  cpu_cores: number;
+  cpu_threads: number;
+  virtualization_system: string;
+  virtualization_role: string;
+  boot_time: number;
 }
 
 export interface ServerStatusRequestContext {